@@ -0,0 +1,49 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrLeadershipLost = errors.New("leadership lost")
+)
+
+// LockBackend is the storage which a single election relies on,
+// implementations should be safe for concurrent use
+type LockBackend interface {
+	// Campaign blocks until identity is elected as leader, ctx is done or error occurs
+	Campaign(ctx context.Context, identity string) error
+	// Renew confirms and extends the leadership acquired by the last Campaign,
+	// ErrLeadershipLost is returned once it has gone
+	Renew(ctx context.Context) error
+	// Token returns the fencing token of leadership acquired by the last Campaign,
+	// it increases monotonically across leaderships of the same election
+	Token() int64
+	// Resign gives up the leadership if held
+	Resign(ctx context.Context) error
+	// Lost returns a channel which is closed once the leadership acquired by the
+	// last Campaign is lost, watching stops when ctx is done
+	Lost(ctx context.Context) <-chan struct{}
+	// Leader returns identity of the current leader, ErrNonLeaderElected if absent
+	Leader(ctx context.Context) (string, error)
+	// Observe streams identity of the leader on every change until ctx is done
+	Observe(ctx context.Context) <-chan string
+	// Close releases underlying resources, leadership is given up as well
+	Close() error
+}
+
+func electionKey(prefix, group string) string {
+	return "/" + prefix + "/" + group
+}
+
+type fencingTokenKey struct{}
+
+// FencingToken returns the fencing token carried by the context passed to
+// OnStartedLeading. Downstream writes should carry it and storage should
+// reject tokens lower than the highest one seen, so a stale leader can't
+// overwrite its successor
+func FencingToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(fencingTokenKey{}).(int64)
+	return token, ok
+}
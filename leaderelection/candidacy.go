@@ -0,0 +1,27 @@
+package leaderelection
+
+import (
+	"context"
+	"time"
+)
+
+// Candidate is an instance taking part in the election
+type Candidate struct {
+	Identity string
+	// Priority is compared among candidates, the higher one preempts lower leader
+	Priority int
+}
+
+// Candidacy is implemented by backends which are able to publish candidates,
+// priority preemption and handover to a specific target rely on it
+type Candidacy interface {
+	// Register announces candidate until ctx is done
+	Register(ctx context.Context, c Candidate) error
+	// Candidates lists the announced candidates
+	Candidates(ctx context.Context) ([]Candidate, error)
+	// Nominate asks other candidates to back off for ttl, so that identity wins
+	// the next campaign. Empty identity clears the nominee
+	Nominate(ctx context.Context, identity string, ttl time.Duration) error
+	// Nominee returns the nominee, empty if none
+	Nominee(ctx context.Context) (string, error)
+}
@@ -0,0 +1,91 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultLeaseDuration = 15 * time.Second
+
+	// etcd lease TTL is in seconds
+	etcdMinLeaseDuration = time.Second
+	// Ratios of defaults to LeaseDuration, which are 10s and 2s of the default 15s
+	defaultRenewDeadlineRatio = 2.0 / 3
+	defaultRetryPeriodRatio   = 2.0 / 15
+)
+
+var (
+	ErrEmptyGroup           = errors.New("group is required")
+	ErrEmptyIdentity        = errors.New("identity is required")
+	ErrNoBackend            = errors.New("either Backend or ETCDClient is required")
+	ErrInvalidLeaseDuration = errors.New("LeaseDuration should be positive, and no less than a second with etcd")
+	ErrInvalidRenewDeadline = errors.New("RenewDeadline should be positive and no greater than LeaseDuration")
+	ErrInvalidRetryPeriod   = errors.New("RetryPeriod should be positive and no greater than LeaseDuration")
+)
+
+// setDefaults fills zero values, RenewDeadline and RetryPeriod are derived from LeaseDuration
+func (c *Config) setDefaults() {
+	if c.Prefix == "" {
+		c.Prefix = DefaultPrefix
+	}
+	if c.LeaseDuration == 0 {
+		c.LeaseDuration = DefaultLeaseDuration
+	}
+	if c.RenewDeadline == 0 {
+		c.RenewDeadline = time.Duration(float64(c.LeaseDuration) * defaultRenewDeadlineRatio)
+	}
+	if c.RetryPeriod == 0 {
+		c.RetryPeriod = time.Duration(float64(c.LeaseDuration) * defaultRetryPeriodRatio)
+	}
+}
+
+// Validate checks the config as is, zero durations are invalid though New fills them with defaults
+func (c Config) Validate() error {
+	if c.Group == "" {
+		return ErrEmptyGroup
+	}
+	if c.Identity == "" {
+		return ErrEmptyIdentity
+	}
+	if c.Backend == nil && c.ETCDClient == nil {
+		return ErrNoBackend
+	}
+	if c.LeaseDuration <= 0 || (c.Backend == nil && c.LeaseDuration < etcdMinLeaseDuration) {
+		return ErrInvalidLeaseDuration
+	}
+	if c.RenewDeadline <= 0 || c.RenewDeadline > c.LeaseDuration {
+		return ErrInvalidRenewDeadline
+	}
+	if c.RetryPeriod <= 0 || c.RetryPeriod > c.LeaseDuration {
+		return ErrInvalidRetryPeriod
+	}
+	return nil
+}
+
+// Callbacks are dispatched through the following methods, which skip nil functions
+
+func (c Callbacks) startedLeading(ctx context.Context) {
+	if c.OnStartedLeading != nil {
+		c.OnStartedLeading(ctx)
+	}
+}
+
+func (c Callbacks) stoppedLeading() {
+	if c.OnStoppedLeading != nil {
+		c.OnStoppedLeading()
+	}
+}
+
+func (c Callbacks) newLeader(identity string) {
+	if c.OnNewLeader != nil {
+		c.OnNewLeader(identity)
+	}
+}
+
+func (c Callbacks) event(e Event) {
+	if c.OnEvent != nil {
+		c.OnEvent(e)
+	}
+}
@@ -0,0 +1,88 @@
+package leaderelection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	valid := leaderelection.Config{
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   100 * time.Millisecond,
+		Backend:       store.Backend("/election/test", time.Second),
+		Group:         "test",
+		Identity:      "a",
+	}
+	require.NoError(t, valid.Validate())
+
+	cases := []struct {
+		name   string
+		modify func(c *leaderelection.Config)
+		err    error
+	}{
+		{"empty group", func(c *leaderelection.Config) { c.Group = "" }, leaderelection.ErrEmptyGroup},
+		{"empty identity", func(c *leaderelection.Config) { c.Identity = "" }, leaderelection.ErrEmptyIdentity},
+		{"no backend", func(c *leaderelection.Config) { c.Backend = nil }, leaderelection.ErrNoBackend},
+		{"zero lease", func(c *leaderelection.Config) { c.LeaseDuration = 0 }, leaderelection.ErrInvalidLeaseDuration},
+		{"zero renew deadline", func(c *leaderelection.Config) { c.RenewDeadline = 0 }, leaderelection.ErrInvalidRenewDeadline},
+		{"renew deadline beyond lease", func(c *leaderelection.Config) { c.RenewDeadline = 2 * time.Second }, leaderelection.ErrInvalidRenewDeadline},
+		{"negative retry period", func(c *leaderelection.Config) { c.RetryPeriod = -time.Second }, leaderelection.ErrInvalidRetryPeriod},
+		{"retry period beyond lease", func(c *leaderelection.Config) { c.RetryPeriod = 2 * time.Second }, leaderelection.ErrInvalidRetryPeriod},
+	}
+	for _, tc := range cases {
+		c := valid
+		tc.modify(&c)
+		assert.Equal(t, tc.err, c.Validate(), tc.name)
+	}
+
+	_, err := leaderelection.New(leaderelection.Config{Group: "test", Identity: "a"})
+	assert.Equal(t, leaderelection.ErrNoBackend, err)
+	_, err = leaderelection.New(leaderelection.Config{
+		LeaseDuration: time.Second,
+		RenewDeadline: 2 * time.Second,
+		Backend:       store.Backend("/election/test", time.Second),
+		Group:         "test",
+		Identity:      "a",
+	})
+	assert.Equal(t, leaderelection.ErrInvalidRenewDeadline, err)
+}
+
+func TestElectorDefaultsAndNilCallbacks(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Durations are derived from LeaseDuration, and callbacks are all nil
+	e, err := leaderelection.New(leaderelection.Config{
+		LeaseDuration: 150 * time.Millisecond,
+		Backend:       store.Backend("/election/test", 150*time.Millisecond),
+		Group:         "test",
+		Identity:      "a",
+	})
+	require.NoError(t, err)
+	stopped := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(stopped)
+	}()
+	require.Eventually(t, e.IsLeader, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return e.CurrentLeader() == "a"
+	}, time.Second, 10*time.Millisecond)
+	// Renewals keep the leadership beyond the lease
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, e.IsLeader())
+	assert.NoError(t, e.Healthy())
+
+	cancel()
+	<-stopped
+	assert.False(t, e.IsLeader())
+}
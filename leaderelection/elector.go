@@ -3,13 +3,13 @@ package leaderelection
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/oif/gokit/runtime"
 	"github.com/oif/gokit/wait"
 
 	"github.com/coreos/etcd/clientv3"
-	"github.com/coreos/etcd/clientv3/concurrency"
 )
 
 const (
@@ -35,9 +35,12 @@ type Config struct {
 	// events of the LeaderElector
 	Callbacks Callbacks
 
-	// ETCDClient is used for connection with etcd cluster
+	// ETCDClient is used for connection with etcd cluster, ignored if Backend is set
 	ETCDClient *clientv3.Client
 
+	// Backend is the lock storage of election, an etcd backend of ETCDClient is used if nil
+	Backend LockBackend
+
 	// Prefix is the prefix that used in etcd key construction
 	Prefix string
 
@@ -45,6 +48,13 @@ type Config struct {
 	Group string
 	// Identify is a unique id for current instance
 	Identity string
+
+	// ReCampaign makes Run campaign again once leadership is lost rather than return
+	ReCampaign bool
+
+	// Priority of current instance, leader hands over to the candidate with
+	// higher priority. It takes effect with backends implementing Candidacy
+	Priority int
 }
 
 // LeaderCallbacks are callbacks that are triggered during certain
@@ -55,7 +65,9 @@ type Config struct {
 type Callbacks struct {
 	// OnStartedLeading is called when a LeaderElector client starts leading
 	OnStartedLeading func(ctx context.Context)
-	// OnStoppedLeading is called when a LeaderElector client stops leading
+	// OnStoppedLeading is called when a LeaderElector client stops leading.
+	// It's called once at the end of each leading term, and once when Run
+	// returns without leading since the last call, so Run always ends with it
 	OnStoppedLeading func()
 	// OnNewLeader is called when the client observes a leader that is
 	// not the previously observed leader. This includes the first observed
@@ -66,7 +78,19 @@ type Callbacks struct {
 	OnEvent func(e Event)
 }
 
+// EventType tells what happened in an election event
+type EventType string
+
+const (
+	EventAcquired       EventType = "Acquired"
+	EventRenewed        EventType = "Renewed"
+	EventStopped        EventType = "Stopped"
+	EventCampaignFailed EventType = "CampaignFailed"
+	EventRenewFailed    EventType = "RenewFailed"
+)
+
 type Event struct {
+	Type        EventType
 	Group       string
 	Identity    string
 	RenewTime   time.Time
@@ -76,24 +100,40 @@ type Event struct {
 
 type Elector struct {
 	config        Config
-	el            *concurrency.Election
+	backend       LockBackend
+	ownBackend    bool
 	inFlight      chan struct{}
+	releaseOnce   sync.Once
+	lock          sync.RWMutex
 	currentLeader string
+	leading       bool
+	// stepDownCh is closed to stop the current leadership
+	stepDownCh   chan struct{}
+	backoffUntil time.Time
+	lastRenewed  time.Time
+	// quota limits leading of groups, used by GroupManager for balancing
+	quota leadingQuota
 }
 
+// New fills zero values of c with defaults then validates it
 func New(c Config) (*Elector, error) {
-	if c.Prefix == "" {
-		c.Prefix = DefaultPrefix
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, err
 	}
 	el := new(Elector)
 	el.config = c
 	el.inFlight = make(chan struct{})
-	session, err := concurrency.NewSession(el.config.ETCDClient,
-		concurrency.WithTTL(int(el.config.LeaseDuration.Seconds())))
-	if err != nil {
-		return nil, err
+	el.backend = c.Backend
+	if el.backend == nil {
+		backend, err := NewEtcdBackend(el.config.ETCDClient,
+			electionKey(el.config.Prefix, el.config.Group), el.config.LeaseDuration)
+		if err != nil {
+			return nil, err
+		}
+		el.backend = backend
+		el.ownBackend = true
 	}
-	el.el = concurrency.NewElection(session, "/"+el.config.Prefix+"/"+el.config.Group)
 	return el, nil
 }
 
@@ -108,47 +148,171 @@ func RunOrDie(ctx context.Context, lec Config) {
 
 func (e *Elector) GetLeader() (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), e.config.LeaseDuration)
-	resp, err := e.el.Leader(ctx)
-	if err != nil {
-		cancel()
-		return "", err
-	}
-	if len(resp.Kvs) == 0 {
-		return "", ErrNonLeaderElected
-	}
-	return string(resp.Kvs[0].Value), nil
+	defer cancel()
+	return e.backend.Leader(ctx)
 }
 
+// Observe streams identity of the leader on every change until ctx is done. It
+// doesn't campaign, so followers and non-candidates can track the leader as well
+func (e *Elector) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var last string
+		for {
+			for leader := range e.backend.Observe(ctx) {
+				if leader == last {
+					continue
+				}
+				last = leader
+				select {
+				case ch <- leader:
+				case <-ctx.Done():
+					return
+				}
+			}
+			// Stream is broken(e.g. watch canceled by compaction), subscribe again later
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.config.RetryPeriod):
+			}
+		}
+	}()
+	return ch
+}
+
+// IsLeader reports whether current instance is leading, which turns false as
+// soon as leadership lost rather than waiting for a new leader observed
 func (e *Elector) IsLeader() bool {
-	return e.currentLeader == e.config.Identity
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.leading
+}
+
+// CurrentLeader returns the last observed leader, empty if none observed yet
+func (e *Elector) CurrentLeader() string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.currentLeader
 }
 
 func (e *Elector) Run(ctx context.Context) {
+	// stopped is whether OnStoppedLeading was called by the last leading term
+	stopped := false
 	defer func() {
 		runtime.HandleCrash()
-		e.config.Callbacks.OnStoppedLeading()
+		if !stopped {
+			e.config.Callbacks.stoppedLeading()
+		}
 	}()
-	// Acquire leadership
-	if !e.acquire(ctx) {
-		// Failed
-		return
+	ctx, cancel := e.releasable(ctx)
+	defer cancel()
+	go e.observe(ctx)
+	e.register(ctx)
+	for {
+		// Acquire leadership
+		if !e.acquire(ctx) {
+			// Failed
+			return
+		}
+		reason := e.lead(ctx, nil)
+		stopped = true
+		switch reason {
+		case errSteppedDown:
+			// Stay as a candidate
+		case ErrLeadershipLost:
+			if !e.config.ReCampaign {
+				return
+			}
+		default:
+			return
+		}
 	}
-	go e.config.Callbacks.OnStartedLeading(ctx)
-	e.renew(ctx)
 }
 
+// Release stops campaigning and resigns, calls after the first one are no-op
 func (e *Elector) Release(ctx context.Context) {
-	close(e.inFlight)
-	e.config.Callbacks.OnStoppedLeading()
-	e.resign(ctx)
+	e.releaseOnce.Do(func() {
+		close(e.inFlight)
+		e.resign(ctx)
+		if e.ownBackend {
+			e.backend.Close()
+		}
+	})
 }
 
-func (e *Elector) resign(ctx context.Context) {
-	if e.el != nil {
-		timeoutCtx, timeoutCancel := context.WithCancel(ctx)
-		defer timeoutCancel()
-		e.el.Resign(timeoutCtx)
+// releasable returns a context which is canceled once elector released as well
+func (e *Elector) releasable(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-e.inFlight:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// lead runs OnStartedLeading with a context canceled as soon as leading stopped,
+// which carries the fencing token as well. started receives the context too if
+// not nil. Returns the reason of stop, which is nil if ctx is done
+func (e *Elector) lead(ctx context.Context, started func(context.Context)) error {
+	leadingCtx, cancel := context.WithCancel(context.WithValue(ctx, fencingTokenKey{}, e.backend.Token()))
+	stepDownCh := e.setLeading(true)
+	var reason error
+	defer func() {
+		cancel()
+		if reason == errSteppedDown {
+			e.resign(ctx)
+		}
+		e.setLeading(false)
+		if e.quota != nil {
+			e.quota.unclaim(e.config.Group)
+		}
+		e.config.Callbacks.stoppedLeading()
+	}()
+	if started != nil {
+		started(leadingCtx)
+	}
+	go e.config.Callbacks.startedLeading(leadingCtx)
+	reason = e.renew(leadingCtx, stepDownCh)
+	ev := e.newEvent(EventStopped)
+	if reason != nil {
+		ev.Reason = reason.Error()
+	}
+	e.config.Callbacks.event(ev)
+	return reason
+}
+
+// setLeading updates leading state and returns the step down channel of leadership
+func (e *Elector) setLeading(leading bool) chan struct{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.leading = leading
+	e.stepDownCh = nil
+	if leading {
+		e.stepDownCh = make(chan struct{})
+		e.lastRenewed = time.Now()
 	}
+	return e.stepDownCh
+}
+
+// observe delivers OnNewLeader until ctx is done
+func (e *Elector) observe(ctx context.Context) {
+	for leader := range e.Observe(ctx) {
+		e.lock.Lock()
+		e.currentLeader = leader
+		e.lock.Unlock()
+		e.config.Callbacks.newLeader(leader)
+	}
+}
+
+func (e *Elector) resign(ctx context.Context) {
+	timeoutCtx, timeoutCancel := context.WithCancel(ctx)
+	defer timeoutCancel()
+	e.backend.Resign(timeoutCtx)
 }
 
 func (e *Elector) acquire(ctx context.Context) bool {
@@ -156,7 +320,7 @@ func (e *Elector) acquire(ctx context.Context) bool {
 	defer cancel()
 	success := false
 	wait.Keep(func() {
-		success = e.tryAcquireOrRenew(ctx)
+		success = e.tryAcquire(ctx)
 		if !success {
 			// Next retry
 			return
@@ -167,38 +331,96 @@ func (e *Elector) acquire(ctx context.Context) bool {
 	return success
 }
 
-func (e *Elector) renew(ctx context.Context) {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	wait.Keep(func() {
-		e.tryAcquireOrRenew(ctx)
-		maybeNewLeader, err := e.GetLeader()
-		if err == nil {
-			if maybeNewLeader != e.currentLeader {
-				e.config.Callbacks.OnNewLeader(maybeNewLeader)
-				e.currentLeader = maybeNewLeader
+// renew keeps the leadership until ctx is done(returns nil), stepped down or
+// leadership lost. The loss is either notified by backend, rejected by renewal
+// or renewals stalled for LeaseDuration
+func (e *Elector) renew(ctx context.Context, stepDownCh <-chan struct{}) error {
+	lost := e.backend.Lost(ctx)
+	ticker := time.NewTicker(e.config.RetryPeriod)
+	defer ticker.Stop()
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-lost:
+			return ErrLeadershipLost
+		case <-stepDownCh:
+			return errSteppedDown
+		case <-ticker.C:
+		}
+		switch err := e.tryRenew(ctx); err {
+		case nil:
+			lastRenewed = time.Now()
+			if e.preempt(ctx) {
+				return errSteppedDown
+			}
+		case ErrLeadershipLost:
+			return ErrLeadershipLost
+		default:
+			if time.Since(lastRenewed) > e.config.LeaseDuration {
+				return ErrLeadershipLost
 			}
 		}
-	}, e.config.RetryPeriod, false, e.inFlight)
+	}
 }
 
-func (e *Elector) tryAcquireOrRenew(ctx context.Context) bool {
+func (e *Elector) newEvent(t EventType) Event {
 	now := time.Now()
-	ev := Event{
+	return Event{
+		Type:        t,
 		Group:       e.config.Group,
 		Identity:    e.config.Identity,
 		RenewTime:   now,
 		AcquireTime: now,
 	}
+}
+
+func (e *Elector) tryAcquire(ctx context.Context) bool {
+	if !e.mayCampaign(ctx) {
+		return false
+	}
+	ev := e.newEvent(EventCampaignFailed)
 	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, e.config.RenewDeadline)
 	defer timeoutCancel()
-	if err := e.el.Campaign(timeoutCtx, e.config.Identity); err != nil {
+	if err := e.backend.Campaign(timeoutCtx, e.config.Identity); err != nil {
 		// Acquire failed ignore this event
 		if err != context.DeadlineExceeded {
 			ev.Reason = err.Error()
-			e.config.Callbacks.OnEvent(ev)
+			e.config.Callbacks.event(ev)
 		}
 		return false
 	}
+	// Someone may be nominated while campaign was blocking, give way to it
+	if !e.mayCampaign(ctx) {
+		e.resign(ctx)
+		return false
+	}
+	// Other groups may be acquired meanwhile, claim a slot before leading
+	if e.quota != nil && !e.quota.claim(e.config.Group) {
+		e.resign(ctx)
+		return false
+	}
+	e.clearNomination(ctx)
+	e.config.Callbacks.event(e.newEvent(EventAcquired))
 	return true
 }
+
+func (e *Elector) tryRenew(ctx context.Context) error {
+	ev := e.newEvent(EventRenewFailed)
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, e.config.RenewDeadline)
+	defer timeoutCancel()
+	err := e.backend.Renew(timeoutCtx)
+	if err == nil {
+		e.lock.Lock()
+		e.lastRenewed = time.Now()
+		e.lock.Unlock()
+		e.config.Callbacks.event(e.newEvent(EventRenewed))
+		return nil
+	}
+	if ctx.Err() == nil {
+		ev.Reason = err.Error()
+		e.config.Callbacks.event(ev)
+	}
+	return err
+}
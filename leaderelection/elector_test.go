@@ -0,0 +1,191 @@
+package leaderelection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	started    chan context.Context
+	stopped    chan struct{}
+	newLeaders chan string
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		started:    make(chan context.Context, 10),
+		stopped:    make(chan struct{}, 10),
+		newLeaders: make(chan string, 10),
+	}
+}
+
+func (r *recorder) callbacks() leaderelection.Callbacks {
+	return leaderelection.Callbacks{
+		OnStartedLeading: func(ctx context.Context) { r.started <- ctx },
+		OnStoppedLeading: func() { r.stopped <- struct{}{} },
+		OnNewLeader:      func(identity string) { r.newLeaders <- identity },
+		OnEvent:          func(e leaderelection.Event) {},
+	}
+}
+
+func newTestElector(t *testing.T, store *leaderelection.MemoryStore, identity string, r *recorder, opts ...func(*leaderelection.Config)) *leaderelection.Elector {
+	c := leaderelection.Config{
+		LeaseDuration: time.Second,
+		RenewDeadline: 100 * time.Millisecond,
+		RetryPeriod:   10 * time.Millisecond,
+		Callbacks:     r.callbacks(),
+		Backend:       store.Backend("/election/test", time.Second),
+		Group:         "test",
+		Identity:      identity,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	e, err := leaderelection.New(c)
+	require.NoError(t, err)
+	return e
+}
+
+func TestElectorObserve(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ra, rb := newRecorder(), newRecorder()
+	a := newTestElector(t, store, "a", ra)
+	b := newTestElector(t, store, "b", rb)
+	// Follower without campaign
+	observed := b.Observe(ctx)
+
+	go a.Run(ctx)
+	<-ra.started
+	assert.Equal(t, "a", <-ra.newLeaders)
+	assert.Equal(t, "a", <-observed)
+	assert.True(t, a.IsLeader())
+
+	go b.Run(ctx)
+	assert.Equal(t, "a", <-rb.newLeaders)
+	a.Release(ctx)
+	<-rb.started
+	assert.Equal(t, "b", <-rb.newLeaders)
+	assert.Equal(t, "b", <-observed)
+}
+
+func TestElectorLeadershipLost(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := newRecorder()
+	e := newTestElector(t, store, "a", r, func(c *leaderelection.Config) {
+		c.ReCampaign = true
+	})
+	stopped := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(stopped)
+	}()
+	leadingCtx := <-r.started
+	token, ok := leaderelection.FencingToken(leadingCtx)
+	require.True(t, ok)
+	assert.True(t, e.IsLeader())
+
+	store.Expire("/election/test")
+	select {
+	case <-leadingCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("leading context should be canceled once leadership lost")
+	}
+	<-r.stopped
+	// Campaign again
+	leadingCtx = <-r.started
+	assert.NoError(t, leadingCtx.Err())
+	nextToken, ok := leaderelection.FencingToken(leadingCtx)
+	require.True(t, ok)
+	assert.True(t, nextToken > token)
+
+	cancel()
+	<-stopped
+	<-r.stopped
+	assert.Error(t, leadingCtx.Err())
+	assert.False(t, e.IsLeader())
+}
+
+func TestElectorStoppedWithoutLeading(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ra, rb := newRecorder(), newRecorder()
+	a := newTestElector(t, store, "a", ra)
+	go a.Run(ctx)
+	<-ra.started
+
+	b := newTestElector(t, store, "b", rb)
+	stopped := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(stopped)
+	}()
+	assert.Equal(t, "a", <-rb.newLeaders)
+	b.Release(ctx)
+	<-stopped
+	// Run always ends with OnStoppedLeading, even never led
+	select {
+	case <-rb.stopped:
+	default:
+		t.Fatal("OnStoppedLeading should be called once Run returned")
+	}
+	assert.Len(t, rb.started, 0)
+}
+
+func TestElectorHandover(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ra, rb, rc := newRecorder(), newRecorder(), newRecorder()
+	a := newTestElector(t, store, "a", ra)
+	b := newTestElector(t, store, "b", rb)
+	c := newTestElector(t, store, "c", rc)
+	go a.Run(ctx)
+	<-ra.started
+	go b.Run(ctx)
+	go c.Run(ctx)
+	assert.Equal(t, "a", <-rc.newLeaders)
+
+	assert.Equal(t, leaderelection.ErrNotLeader, b.Handover(ctx, "c"))
+	assert.Equal(t, leaderelection.ErrUnknownCandidate, a.Handover(ctx, "d"))
+	require.NoError(t, a.Handover(ctx, "c"))
+	<-ra.stopped
+	<-rc.started
+	assert.True(t, c.IsLeader())
+	assert.False(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+}
+
+func TestElectorPriorityPreemption(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	low, high := newRecorder(), newRecorder()
+	l := newTestElector(t, store, "low", low)
+	go l.Run(ctx)
+	<-low.started
+
+	h := newTestElector(t, store, "high", high, func(c *leaderelection.Config) {
+		c.Priority = 1
+	})
+	go h.Run(ctx)
+	<-low.stopped
+	<-high.started
+	assert.True(t, h.IsLeader())
+	assert.False(t, l.IsLeader())
+}
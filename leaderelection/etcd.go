@@ -0,0 +1,437 @@
+package leaderelection
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/clientv3/concurrency"
+)
+
+const (
+	// Candidates and nominee live in their own namespaces instead of next to the
+	// election key, since every key under an election prefix takes part in that
+	// election, e.g. candidates of group "a" would campaign in group "a.candidates".
+	// Election keys starting with "/" never fall into them
+	etcdCandidatesNamespace = "_candidates"
+	etcdNomineeNamespace    = "_nominee"
+)
+
+var (
+	_ LockBackend = new(EtcdBackend)
+	_ Candidacy   = new(EtcdBackend)
+)
+
+// EtcdSession is a session lease shared by backends of different election
+// keys, so that a process holds a single lease for many elections. The
+// session is recreated by next Campaign once it's gone
+type EtcdSession struct {
+	client  *clientv3.Client
+	ttl     time.Duration
+	lock    sync.Mutex
+	session *concurrency.Session
+}
+
+// NewEtcdSession creates a session whose lease lives for ttl
+func NewEtcdSession(client *clientv3.Client, ttl time.Duration) (*EtcdSession, error) {
+	s := &EtcdSession{
+		client: client,
+		ttl:    ttl,
+	}
+	if err := s.newSession(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSession should be called with lock held
+func (s *EtcdSession) newSession() error {
+	session, err := concurrency.NewSession(s.client, concurrency.WithTTL(int(s.ttl.Seconds())))
+	if err != nil {
+		return err
+	}
+	s.session = session
+	return nil
+}
+
+func (s *EtcdSession) current() *concurrency.Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.session
+}
+
+// alive returns current session, a new one is created if it's gone
+func (s *EtcdSession) alive() (*concurrency.Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-s.session.Done():
+		if err := s.newSession(); err != nil {
+			return nil, err
+		}
+	default:
+	}
+	return s.session, nil
+}
+
+// Backend returns a LockBackend of election key on the session
+func (s *EtcdSession) Backend(key string) *EtcdBackend {
+	session := s.current()
+	return &EtcdBackend{
+		shared:   s,
+		client:   s.client,
+		key:      key,
+		ttl:      s.ttl,
+		session:  session,
+		election: concurrency.NewElection(session, key),
+	}
+}
+
+// Close revokes the session lease, which deletes campaign keys of all backends
+func (s *EtcdSession) Close() error {
+	return s.current().Close()
+}
+
+// sharedBackends returns newBackend if given, otherwise backends sharing a new session of client
+func sharedBackends(newBackend func(key string) LockBackend, client *clientv3.Client,
+	ttl time.Duration) (func(key string) LockBackend, *EtcdSession, error) {
+	if newBackend != nil {
+		return newBackend, nil, nil
+	}
+	if client == nil {
+		return nil, nil, ErrNoBackend
+	}
+	session, err := NewEtcdSession(client, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(key string) LockBackend {
+		return session.Backend(key)
+	}, session, nil
+}
+
+// EtcdBackend is a LockBackend adapter of concurrency.Election
+type EtcdBackend struct {
+	shared *EtcdSession
+	// ownSession is true if the session is created for the backend only
+	ownSession bool
+	client     *clientv3.Client
+	key        string
+	ttl        time.Duration
+	lock       sync.Mutex
+	session    *concurrency.Session
+	election   *concurrency.Election
+	// nomineeLease is the lease granted by last Nominate, revoked by the next one
+	nomineeLock  sync.Mutex
+	nomineeLease clientv3.LeaseID
+}
+
+// NewEtcdBackend creates a session whose lease lives for ttl and an election on key
+func NewEtcdBackend(client *clientv3.Client, key string, ttl time.Duration) (*EtcdBackend, error) {
+	s, err := NewEtcdSession(client, ttl)
+	if err != nil {
+		return nil, err
+	}
+	b := s.Backend(key)
+	b.ownSession = true
+	return b, nil
+}
+
+func (b *EtcdBackend) current() (*concurrency.Session, *concurrency.Election) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.session, b.election
+}
+
+// alive returns current session of shared one, the election is recreated if session changed
+func (b *EtcdBackend) alive() (*concurrency.Session, *concurrency.Election, error) {
+	session, err := b.shared.alive()
+	if err != nil {
+		return nil, nil, err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.session != session {
+		b.session = session
+		b.election = concurrency.NewElection(session, b.key)
+	}
+	return b.session, b.election, nil
+}
+
+func (b *EtcdBackend) Campaign(ctx context.Context, identity string) error {
+	_, election, err := b.alive()
+	if err != nil {
+		return err
+	}
+	return election.Campaign(ctx, identity)
+}
+
+// Token is the create revision of campaign key
+func (b *EtcdBackend) Token() int64 {
+	_, election := b.current()
+	return election.Rev()
+}
+
+// Renew checks the session is alive and the campaign key is still owned by it,
+// lease keepalive itself is maintained by the session
+func (b *EtcdBackend) Renew(ctx context.Context) error {
+	session, election := b.current()
+	select {
+	case <-session.Done():
+		return ErrLeadershipLost
+	default:
+	}
+	key := election.Key()
+	if key == "" {
+		return ErrLeadershipLost
+	}
+	resp, err := b.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 || resp.Kvs[0].Lease != int64(session.Lease()) {
+		return ErrLeadershipLost
+	}
+	return nil
+}
+
+func (b *EtcdBackend) Resign(ctx context.Context) error {
+	_, election := b.current()
+	return election.Resign(ctx)
+}
+
+// Lost is closed once the session is gone(lease expired or revoked) or the campaign key is deleted
+func (b *EtcdBackend) Lost(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+	session, election := b.current()
+	key := election.Key()
+	go func() {
+		if key == "" {
+			close(lost)
+			return
+		}
+		watchRev := election.Rev() + 1
+		for {
+			watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+			isLost := watchLost(ctx, session, b.client.Watch(watchCtx, key, clientv3.WithRev(watchRev)))
+			cancel()
+			if isLost {
+				close(lost)
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			// Watch is broken, confirm the ownership then watch from current revision
+			resp, err := b.client.Get(ctx, key)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				case <-session.Done():
+					close(lost)
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			if len(resp.Kvs) == 0 || resp.Kvs[0].Lease != int64(session.Lease()) {
+				close(lost)
+				return
+			}
+			watchRev = resp.Header.Revision + 1
+		}
+	}()
+	return lost
+}
+
+// watchLost returns true if leadership lost, false if ctx is done or watch broken
+func watchLost(ctx context.Context, session *concurrency.Session, watchCh clientv3.WatchChan) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-session.Done():
+			return true
+		case resp, ok := <-watchCh:
+			if !ok || resp.Err() != nil {
+				return false
+			}
+			for _, ev := range resp.Events {
+				if ev.Type == clientv3.EventTypeDelete {
+					return true
+				}
+			}
+		}
+	}
+}
+
+func (b *EtcdBackend) Leader(ctx context.Context) (string, error) {
+	_, election := b.current()
+	resp, err := election.Leader(ctx)
+	if err != nil {
+		if err == concurrency.ErrElectionNoLeader {
+			return "", ErrNonLeaderElected
+		}
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", ErrNonLeaderElected
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+func (b *EtcdBackend) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	_, election := b.current()
+	go func() {
+		defer close(ch)
+		var last string
+		for resp := range election.Observe(ctx) {
+			if len(resp.Kvs) == 0 {
+				continue
+			}
+			leader := string(resp.Kvs[0].Value)
+			if leader == last {
+				continue
+			}
+			last = leader
+			select {
+			case ch <- leader:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (b *EtcdBackend) candidatesPrefix() string {
+	return etcdCandidatesNamespace + b.key + "/"
+}
+
+func (b *EtcdBackend) nomineeKey() string {
+	return etcdNomineeNamespace + b.key
+}
+
+// Register puts candidate with the session lease and puts it again once session recreated
+func (b *EtcdBackend) Register(ctx context.Context, c Candidate) error {
+	key := b.candidatesPrefix() + c.Identity
+	value := strconv.Itoa(c.Priority)
+	session := b.shared.current()
+	if _, err := b.client.Put(ctx, key, value, clientv3.WithLease(session.Lease())); err != nil {
+		return err
+	}
+	go func() {
+		// ctx is done on every return, so the key is deleted with a fresh one
+		defer func() {
+			deleteCtx, cancel := context.WithTimeout(context.Background(), b.ttl)
+			b.client.Delete(deleteCtx, key)
+			cancel()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-session.Done():
+			}
+			// Wait for the next session created by Campaign
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+				next := b.shared.current()
+				if next == session {
+					continue
+				}
+				if _, err := b.client.Put(ctx, key, value, clientv3.WithLease(next.Lease())); err == nil {
+					session = next
+					break
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *EtcdBackend) Candidates(ctx context.Context) ([]Candidate, error) {
+	prefix := b.candidatesPrefix()
+	resp, err := b.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]Candidate, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		priority, err := strconv.Atoi(string(kv.Value))
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Identity: strings.TrimPrefix(string(kv.Key), prefix),
+			Priority: priority,
+		})
+	}
+	return candidates, nil
+}
+
+// Nominate puts the nominee with a dedicated lease of ttl, empty identity deletes it
+func (b *EtcdBackend) Nominate(ctx context.Context, identity string, ttl time.Duration) error {
+	key := b.nomineeKey()
+	b.nomineeLock.Lock()
+	defer b.nomineeLock.Unlock()
+	if identity == "" {
+		if _, err := b.client.Delete(ctx, key); err != nil {
+			return err
+		}
+		b.revokeNominee(ctx, 0)
+		return nil
+	}
+	seconds := int64(ttl.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	lease, err := b.client.Grant(ctx, seconds)
+	if err != nil {
+		return err
+	}
+	if _, err = b.client.Put(ctx, key, identity, clientv3.WithLease(lease.ID)); err != nil {
+		b.client.Revoke(ctx, lease.ID)
+		return err
+	}
+	b.revokeNominee(ctx, lease.ID)
+	return nil
+}
+
+// revokeNominee revokes the lease of last nomination and keeps next instead,
+// errors are ignored since the lease expires by itself anyway
+func (b *EtcdBackend) revokeNominee(ctx context.Context, next clientv3.LeaseID) {
+	if b.nomineeLease != 0 {
+		b.client.Revoke(ctx, b.nomineeLease)
+	}
+	b.nomineeLease = next
+}
+
+func (b *EtcdBackend) Nominee(ctx context.Context) (string, error) {
+	resp, err := b.client.Get(ctx, b.nomineeKey())
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Close revokes the session lease if it's owned by the backend, which deletes
+// the campaign key as well. Otherwise leadership is resigned only
+func (b *EtcdBackend) Close() error {
+	if b.ownSession {
+		return b.shared.Close()
+	}
+	return b.Resign(context.Background())
+}
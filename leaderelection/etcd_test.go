@@ -0,0 +1,155 @@
+package leaderelection_test
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/embed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freeURL(t *testing.T) url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+// startEtcd runs an embedded single member etcd and returns a client of it
+func startEtcd(t *testing.T) *clientv3.Client {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	cfg.LCUrls, cfg.ACUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("embedded etcd is not ready")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{clientURL.String()},
+		DialTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func waitClosed(t *testing.T, ch <-chan struct{}, msg string) {
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal(msg)
+	}
+}
+
+func TestEtcdBackend(t *testing.T) {
+	client := startEtcd(t)
+	ctx := context.Background()
+	a, err := leaderelection.NewEtcdBackend(client, "/election/test", 5*time.Second)
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := leaderelection.NewEtcdBackend(client, "/election/test", 5*time.Second)
+	require.NoError(t, err)
+	defer b.Close()
+
+	_, err = a.Leader(ctx)
+	assert.Equal(t, leaderelection.ErrNonLeaderElected, err)
+	observeCtx, observeCancel := context.WithCancel(ctx)
+	defer observeCancel()
+	observed := b.Observe(observeCtx)
+
+	require.NoError(t, a.Campaign(ctx, "a"))
+	assert.Equal(t, "a", <-observed)
+	require.NoError(t, a.Renew(ctx))
+	firstToken := a.Token()
+	assert.True(t, firstToken > 0)
+	leader, err := b.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", leader)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, b.Campaign(timeoutCtx, "b"))
+	cancel()
+
+	lost := a.Lost(ctx)
+	campaigned := make(chan error, 1)
+	go func() {
+		campaigned <- b.Campaign(ctx, "b")
+	}()
+	require.NoError(t, a.Resign(ctx))
+	waitClosed(t, lost, "lost is not closed after resigned")
+	require.NoError(t, <-campaigned)
+	assert.Equal(t, "b", <-observed)
+	assert.True(t, b.Token() > firstToken)
+	assert.Equal(t, leaderelection.ErrLeadershipLost, a.Renew(ctx))
+}
+
+func TestEtcdSession(t *testing.T) {
+	client := startEtcd(t)
+	ctx := context.Background()
+	session, err := leaderelection.NewEtcdSession(client, 5*time.Second)
+	require.NoError(t, err)
+	x := session.Backend("/election/x")
+	y := session.Backend("/election/y")
+
+	require.NoError(t, x.Campaign(ctx, "a"))
+	require.NoError(t, y.Campaign(ctx, "a"))
+	lostX, lostY := x.Lost(ctx), y.Lost(ctx)
+
+	// Revoking the shared lease loses all elections of the session
+	require.NoError(t, session.Close())
+	waitClosed(t, lostX, "x is not lost after session closed")
+	waitClosed(t, lostY, "y is not lost after session closed")
+	assert.Equal(t, leaderelection.ErrLeadershipLost, x.Renew(ctx))
+	assert.Equal(t, leaderelection.ErrLeadershipLost, y.Renew(ctx))
+
+	// The session is recreated by the next campaign
+	require.NoError(t, x.Campaign(ctx, "a"))
+	require.NoError(t, x.Renew(ctx))
+	require.NoError(t, session.Close())
+}
+
+func TestEtcdCandidacy(t *testing.T) {
+	client := startEtcd(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	group, err := leaderelection.NewEtcdBackend(client, "/election/group", 5*time.Second)
+	require.NoError(t, err)
+	defer group.Close()
+	// A group named after candidates of another group is a distinct election
+	other, err := leaderelection.NewEtcdBackend(client, "/election/group.candidates", 5*time.Second)
+	require.NoError(t, err)
+	defer other.Close()
+
+	require.NoError(t, group.Register(ctx, leaderelection.Candidate{Identity: "a", Priority: 2}))
+	candidates, err := group.Candidates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []leaderelection.Candidate{{Identity: "a", Priority: 2}}, candidates)
+	_, err = other.Leader(ctx)
+	assert.Equal(t, leaderelection.ErrNonLeaderElected, err)
+	candidates, err = other.Candidates(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+
+	require.NoError(t, group.Nominate(ctx, "a", 5*time.Second))
+	nominee, err := group.Nominee(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", nominee)
+	nominee, err = other.Nominee(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, nominee)
+}
@@ -31,6 +31,9 @@ func (i *instance) onStopped() {
 }
 
 func (i *instance) onEvent(e leaderelection.Event) {
+	if e.Type == leaderelection.EventRenewed {
+		return
+	}
 	fmt.Printf("[%s/%s] ev: %v\n", i.group, i.identity, e)
 }
 
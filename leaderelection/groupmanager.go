@@ -0,0 +1,246 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/oif/gokit/runtime"
+	"github.com/oif/gokit/wait"
+)
+
+var (
+	ErrNoGroups = errors.New("no groups to campaign")
+)
+
+// GroupCallbacks are per group lifecycle callbacks of GroupManager
+type GroupCallbacks struct {
+	// OnStartedLeading is called when starts leading group, ctx is canceled once stopped
+	OnStartedLeading func(ctx context.Context, group string)
+	// OnStoppedLeading is called when stops leading group
+	OnStoppedLeading func(group string)
+	// OnNewLeader is called when a new leader of group observed
+	OnNewLeader func(group, identity string)
+}
+
+type GroupManagerConfig struct {
+	// Config is the template of group electors. Group and Backend are ignored,
+	// and Callbacks other than OnEvent are replaced by GroupCallbacks
+	Config
+	// Groups to campaign, all instances are expected to campaign the same groups
+	Groups []string
+	// GroupCallbacks are called with the group
+	GroupCallbacks GroupCallbacks
+	// NewBackend creates the backend of election key. Backends sharing a single
+	// etcd session of ETCDClient are used if nil
+	NewBackend func(key string) LockBackend
+}
+
+// leadingQuota limits groups led by an instance at once
+type leadingQuota interface {
+	// admit reports whether campaign is allowed
+	admit() bool
+	// claim takes a slot for group once acquired, false if none left
+	claim(group string) bool
+	// unclaim frees the slot of group once stopped leading
+	unclaim(group string)
+}
+
+// GroupManager campaigns for many groups, and holds no more than its share of
+// groups, which is the sum of each group divided by its live candidates rounded
+// up. Candidates are known from backends implementing Candidacy, without it a
+// group counts as a whole
+type GroupManager struct {
+	config   GroupManagerConfig
+	session  *EtcdSession
+	groups   []string
+	electors map[string]*Elector
+	lock     sync.RWMutex
+	// claimed are groups acquired and not stopped leading yet
+	claimed     map[string]bool
+	share       int
+	released    chan struct{}
+	releaseOnce sync.Once
+}
+
+func NewGroupManager(c GroupManagerConfig) (*GroupManager, error) {
+	if len(c.Groups) == 0 {
+		return nil, ErrNoGroups
+	}
+	c.setDefaults()
+	m := &GroupManager{
+		config:   c,
+		groups:   append([]string(nil), c.Groups...),
+		electors: make(map[string]*Elector, len(c.Groups)),
+		claimed:  make(map[string]bool),
+		share:    len(c.Groups),
+		released: make(chan struct{}),
+	}
+	sort.Strings(m.groups)
+	newBackend, session, err := sharedBackends(c.NewBackend, c.ETCDClient, c.LeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+	m.session = session
+	for _, group := range m.groups {
+		ec := c.Config
+		ec.Group = group
+		ec.Backend = newBackend(electionKey(c.Prefix, group))
+		ec.ReCampaign = true
+		ec.Callbacks = m.callbacks(group)
+		e, err := New(ec)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		e.quota = m
+		m.electors[group] = e
+	}
+	return m, nil
+}
+
+func (m *GroupManager) callbacks(group string) Callbacks {
+	gc := m.config.GroupCallbacks
+	return Callbacks{
+		OnStartedLeading: func(ctx context.Context) {
+			if gc.OnStartedLeading != nil {
+				gc.OnStartedLeading(ctx, group)
+			}
+		},
+		OnStoppedLeading: func() {
+			if gc.OnStoppedLeading != nil {
+				gc.OnStoppedLeading(group)
+			}
+		},
+		OnNewLeader: func(identity string) {
+			if gc.OnNewLeader != nil {
+				gc.OnNewLeader(group, identity)
+			}
+		},
+		OnEvent: m.config.Callbacks.OnEvent,
+	}
+}
+
+// admit allows campaign while holding less groups than the share
+func (m *GroupManager) admit() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.claimed) < m.share
+}
+
+// claim is called by the elector of group right after acquired, so groups
+// acquired at the same time never exceed the share
+func (m *GroupManager) claim(group string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.claimed) >= m.share {
+		return false
+	}
+	m.claimed[group] = true
+	return true
+}
+
+// unclaim is called by the elector of group once stopped leading
+func (m *GroupManager) unclaim(group string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.claimed, group)
+}
+
+// Elector returns the elector of group, nil if group is unknown
+func (m *GroupManager) Elector(group string) *Elector {
+	return m.electors[group]
+}
+
+// Leading returns the sorted groups currently leading
+func (m *GroupManager) Leading() []string {
+	var groups []string
+	for _, group := range m.groups {
+		if m.electors[group].IsLeader() {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Run campaigns for all groups and balances them until ctx is done or released
+func (m *GroupManager) Run(ctx context.Context) {
+	defer runtime.HandleCrash()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.released:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var wg wait.Group
+	for _, e := range m.electors {
+		e := e
+		wg.Run(func() { e.Run(ctx) })
+	}
+	wait.Keep(func() {
+		m.balance(ctx)
+	}, m.config.RetryPeriod, false, ctx.Done())
+	wg.Wait()
+}
+
+// balance updates the share by live candidates of each group and steps down
+// groups beyond it. Groups whose candidates are unknown count as a whole
+func (m *GroupManager) balance(ctx context.Context) {
+	var load float64
+	for _, group := range m.groups {
+		candidacy, ok := m.electors[group].backend.(Candidacy)
+		if !ok {
+			load++
+			continue
+		}
+		candidates, err := candidacy.Candidates(ctx)
+		if err != nil {
+			// Keep the share until candidates are known
+			return
+		}
+		if len(candidates) == 0 {
+			load++
+			continue
+		}
+		load += 1 / float64(len(candidates))
+	}
+	// Tolerate rounding errors of the sum, e.g. 3 groups of 3 candidates
+	share := int(math.Ceil(load - 1e-9))
+	m.lock.Lock()
+	m.share = share
+	m.lock.Unlock()
+
+	leading := m.Leading()
+	if len(leading) <= share {
+		return
+	}
+	for _, group := range leading[share:] {
+		m.electors[group].stepDown(ctx, "")
+	}
+}
+
+// Release releases electors of all groups and closes the shared session,
+// calls after the first one are no-op
+func (m *GroupManager) Release(ctx context.Context) {
+	m.releaseOnce.Do(func() {
+		close(m.released)
+		for _, e := range m.electors {
+			e.Release(ctx)
+		}
+		m.close()
+	})
+}
+
+func (m *GroupManager) close() {
+	for _, e := range m.electors {
+		e.backend.Close()
+	}
+	if m.session != nil {
+		m.session.Close()
+	}
+}
@@ -0,0 +1,172 @@
+package leaderelection_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGroupManager(t *testing.T, store *leaderelection.MemoryStore, identity string, groups []string, gc leaderelection.GroupCallbacks) *leaderelection.GroupManager {
+	return newTestGroupManagerWithBackend(t, identity, groups, gc, func(key string) leaderelection.LockBackend {
+		return store.Backend(key, 200*time.Millisecond)
+	})
+}
+
+func newTestGroupManagerWithBackend(t *testing.T, identity string, groups []string, gc leaderelection.GroupCallbacks,
+	newBackend func(key string) leaderelection.LockBackend) *leaderelection.GroupManager {
+	m, err := leaderelection.NewGroupManager(leaderelection.GroupManagerConfig{
+		Config: leaderelection.Config{
+			LeaseDuration: 200 * time.Millisecond,
+			RenewDeadline: 50 * time.Millisecond,
+			RetryPeriod:   10 * time.Millisecond,
+			Identity:      identity,
+		},
+		Groups:         groups,
+		GroupCallbacks: gc,
+		NewBackend:     newBackend,
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestGroupManagerBalance(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	groups := []string{"p0", "p1", "p2", "p3"}
+
+	var lock sync.Mutex
+	started := make(map[string]int)
+	a := newTestGroupManager(t, store, "a", groups, leaderelection.GroupCallbacks{
+		OnStartedLeading: func(ctx context.Context, group string) {
+			lock.Lock()
+			started[group]++
+			lock.Unlock()
+		},
+	})
+	go a.Run(ctx)
+	require.Eventually(t, func() bool {
+		return len(a.Leading()) == len(groups)
+	}, time.Second, 10*time.Millisecond)
+	lock.Lock()
+	assert.Len(t, started, len(groups))
+	lock.Unlock()
+
+	b := newTestGroupManager(t, store, "b", groups, leaderelection.GroupCallbacks{})
+	go b.Run(ctx)
+	require.Eventually(t, func() bool {
+		return len(a.Leading()) == 2 && len(b.Leading()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, groups, append(a.Leading(), b.Leading()...))
+
+	// Groups of a released instance are taken over
+	a.Release(ctx)
+	require.Eventually(t, func() bool {
+		return len(b.Leading()) == len(groups)
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestGroupManagerNoGroups(t *testing.T) {
+	_, err := leaderelection.NewGroupManager(leaderelection.GroupManagerConfig{})
+	assert.Equal(t, leaderelection.ErrNoGroups, err)
+}
+
+// churnBackend loses leadership right after acquired for losses times, then
+// never acquires again and closes done
+type churnBackend struct {
+	*leaderelection.MemoryBackend
+	store     *leaderelection.MemoryStore
+	key       string
+	losses    int32
+	campaigns int32
+	doneOnce  sync.Once
+	done      chan struct{}
+}
+
+func (b *churnBackend) Campaign(ctx context.Context, identity string) error {
+	if atomic.AddInt32(&b.campaigns, 1) > b.losses {
+		b.doneOnce.Do(func() { close(b.done) })
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := b.MemoryBackend.Campaign(ctx, identity); err != nil {
+		return err
+	}
+	b.store.Expire(b.key)
+	return nil
+}
+
+// gatedBackend campaigns once gate closed
+type gatedBackend struct {
+	*leaderelection.MemoryBackend
+	gate <-chan struct{}
+}
+
+func (b *gatedBackend) Campaign(ctx context.Context, identity string) error {
+	select {
+	case <-b.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.MemoryBackend.Campaign(ctx, identity)
+}
+
+func TestGroupManagerFastLoss(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	groups := []string{"p0", "p1"}
+	churn := &churnBackend{losses: 20, done: make(chan struct{})}
+	a := newTestGroupManagerWithBackend(t, "a", groups, leaderelection.GroupCallbacks{},
+		func(key string) leaderelection.LockBackend {
+			backend := store.Backend(key, 200*time.Millisecond)
+			// Another candidate of both groups, so the share of a is 1
+			require.NoError(t, backend.Register(ctx, leaderelection.Candidate{Identity: "b"}))
+			if key == "/"+leaderelection.DefaultPrefix+"/p1" {
+				churn.MemoryBackend, churn.store, churn.key = backend, store, key
+				return churn
+			}
+			return &gatedBackend{MemoryBackend: backend, gate: churn.done}
+		})
+	go a.Run(ctx)
+
+	// p1 is acquired then lost quickly over and over, none of them should be
+	// taken as leading afterwards, which would keep p0 out of the share
+	require.Eventually(t, func() bool {
+		leading := a.Leading()
+		return len(leading) == 1 && leading[0] == "p0"
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.False(t, a.Elector("p1").IsLeader())
+}
+
+func TestGroupManagerCandidatesPerGroup(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	groups := []string{"p0", "p1"}
+	// b is a candidate of p0 only, so a is left the only candidate of p1 and
+	// its share is half of p0 and the whole p1
+	a := newTestGroupManagerWithBackend(t, "a", groups, leaderelection.GroupCallbacks{},
+		func(key string) leaderelection.LockBackend {
+			backend := store.Backend(key, 200*time.Millisecond)
+			if key == "/"+leaderelection.DefaultPrefix+"/p0" {
+				require.NoError(t, backend.Register(ctx, leaderelection.Candidate{Identity: "b"}))
+			}
+			return backend
+		})
+	go a.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return len(a.Leading()) == len(groups)
+	}, time.Second, 10*time.Millisecond)
+	// Stays leading both after balanced
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, groups, a.Leading())
+}
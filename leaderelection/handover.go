@@ -0,0 +1,154 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotLeader        = errors.New("not the leader")
+	ErrUnknownCandidate = errors.New("unknown candidate")
+
+	errSteppedDown = errors.New("stepped down")
+)
+
+// Handover steps down and waits until another leader elected, which must be
+// target if given. Candidates other than target back off for LeaseDuration
+// when backend implements Candidacy, without it target is only a hope. Current
+// instance backs off for LeaseDuration as well and stays as a candidate
+func (e *Elector) Handover(ctx context.Context, target string) error {
+	if target == e.config.Identity {
+		return nil
+	}
+	// Subscribe before stepping down, otherwise the successor may be missed
+	observeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	observed := e.Observe(observeCtx)
+	if err := e.stepDown(ctx, target); err != nil {
+		return err
+	}
+	for leader := range observed {
+		if leader != e.config.Identity && (target == "" || leader == target) {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func (e *Elector) stepDown(ctx context.Context, target string) error {
+	if !e.IsLeader() {
+		return ErrNotLeader
+	}
+	if candidacy, ok := e.backend.(Candidacy); ok && target != "" {
+		candidates, err := candidacy.Candidates(ctx)
+		if err != nil {
+			return err
+		}
+		if !hasCandidate(candidates, target) {
+			return ErrUnknownCandidate
+		}
+		if err = candidacy.Nominate(ctx, target, e.config.LeaseDuration); err != nil {
+			return err
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.stepDownCh == nil {
+		return ErrNotLeader
+	}
+	e.backoffUntil = time.Now().Add(e.config.LeaseDuration)
+	close(e.stepDownCh)
+	e.stepDownCh = nil
+	return nil
+}
+
+func hasCandidate(candidates []Candidate, identity string) bool {
+	for _, c := range candidates {
+		if c.Identity == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// register announces current instance as a candidate until ctx is done
+func (e *Elector) register(ctx context.Context) {
+	candidacy, ok := e.backend.(Candidacy)
+	if !ok {
+		return
+	}
+	err := candidacy.Register(ctx, Candidate{
+		Identity: e.config.Identity,
+		Priority: e.config.Priority,
+	})
+	if err != nil {
+		ev := e.newEvent(EventCampaignFailed)
+		ev.Reason = err.Error()
+		e.config.Callbacks.event(ev)
+	}
+}
+
+// mayCampaign is false while backing off after stepped down, not admitted or someone else nominated
+func (e *Elector) mayCampaign(ctx context.Context) bool {
+	e.lock.RLock()
+	backoffUntil := e.backoffUntil
+	e.lock.RUnlock()
+	if time.Now().Before(backoffUntil) {
+		return false
+	}
+	if e.quota != nil && !e.quota.admit() {
+		return false
+	}
+	candidacy, ok := e.backend.(Candidacy)
+	if !ok {
+		return true
+	}
+	nominee, err := candidacy.Nominee(ctx)
+	return err != nil || nominee == "" || nominee == e.config.Identity
+}
+
+// clearNomination clears the nominee once it's elected
+func (e *Elector) clearNomination(ctx context.Context) {
+	candidacy, ok := e.backend.(Candidacy)
+	if !ok {
+		return
+	}
+	if nominee, err := candidacy.Nominee(ctx); err == nil && nominee == e.config.Identity {
+		candidacy.Nominate(ctx, "", 0)
+	}
+}
+
+// preempt nominates the candidate with the highest priority above current
+// instance, and reports whether leader should step down for it
+func (e *Elector) preempt(ctx context.Context) bool {
+	candidacy, ok := e.backend.(Candidacy)
+	if !ok {
+		return false
+	}
+	candidates, err := candidacy.Candidates(ctx)
+	if err != nil {
+		return false
+	}
+	var preemptor *Candidate
+	for i, c := range candidates {
+		if c.Identity == e.config.Identity || c.Priority <= e.config.Priority {
+			continue
+		}
+		if preemptor == nil || c.Priority > preemptor.Priority ||
+			(c.Priority == preemptor.Priority && c.Identity < preemptor.Identity) {
+			preemptor = &candidates[i]
+		}
+	}
+	if preemptor == nil {
+		return false
+	}
+	if err = candidacy.Nominate(ctx, preemptor.Identity, e.config.LeaseDuration); err != nil {
+		return false
+	}
+	e.lock.Lock()
+	e.backoffUntil = time.Now().Add(e.config.LeaseDuration)
+	e.lock.Unlock()
+	return true
+}
@@ -0,0 +1,70 @@
+package leaderelection
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/oif/gokit/specs/httpresponse"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrRenewStalled = errors.New("leadership renewal stalled")
+)
+
+// Status is a snapshot of the elector
+type Status struct {
+	Group         string    `json:"group"`
+	Identity      string    `json:"identity"`
+	Leader        string    `json:"leader"`
+	IsLeader      bool      `json:"is_leader"`
+	LastRenewTime time.Time `json:"last_renew_time"`
+}
+
+func (e *Elector) Status() Status {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	s := Status{
+		Group:    e.config.Group,
+		Identity: e.config.Identity,
+		Leader:   e.currentLeader,
+		IsLeader: e.leading,
+	}
+	if e.leading {
+		s.LastRenewTime = e.lastRenewed
+	}
+	return s
+}
+
+// Healthy returns ErrRenewStalled if leading but not renewed since the last
+// attempt should have finished, which is RetryPeriod plus RenewDeadline.
+// Followers are always healthy
+func (e *Elector) Healthy() error {
+	s := e.Status()
+	if s.IsLeader && time.Since(s.LastRenewTime) > e.config.RetryPeriod+e.config.RenewDeadline {
+		return ErrRenewStalled
+	}
+	return nil
+}
+
+// HealthHandler responds status of electors, with 503 if any of them is unhealthy
+func HealthHandler(electors ...*Elector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses := make([]Status, 0, len(electors))
+		var err error
+		for _, e := range electors {
+			statuses = append(statuses, e.Status())
+			if healthErr := e.Healthy(); healthErr != nil && err == nil {
+				err = healthErr
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, httpresponse.Construct(
+				httpresponse.WithError(err), httpresponse.WithData(statuses)))
+			return
+		}
+		c.JSON(http.StatusOK, httpresponse.Construct(httpresponse.WithData(statuses)))
+	}
+}
@@ -0,0 +1,88 @@
+package leaderelection_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyBackend fails renewals with a transient error once broken
+type flakyBackend struct {
+	*leaderelection.MemoryBackend
+	broken int32
+}
+
+func (b *flakyBackend) Renew(ctx context.Context) error {
+	if atomic.LoadInt32(&b.broken) == 1 {
+		return errors.New("unavailable")
+	}
+	return b.MemoryBackend.Renew(ctx)
+}
+
+func isLeader(t *testing.T, registry *prometheus.Registry) float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == "leader_election_is_leader" {
+			return f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatal("is_leader metric not found")
+	return 0
+}
+
+func TestMetricsAndHealth(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	metrics := leaderelection.NewMetrics("", registry)
+	backend := &flakyBackend{MemoryBackend: store.Backend("/election/test", time.Second)}
+	r := newRecorder()
+	e := newTestElector(t, store, "a", r, func(c *leaderelection.Config) {
+		c.Callbacks = metrics.Callbacks(c.Callbacks)
+		c.Backend = backend
+	})
+
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	engine.GET("/healthz", leaderelection.HealthHandler(e))
+	health := func() int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, health())
+
+	stopped := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(stopped)
+	}()
+	<-r.started
+	assert.Equal(t, float64(1), isLeader(t, registry))
+	assert.Equal(t, http.StatusOK, health())
+
+	atomic.StoreInt32(&backend.broken, 1)
+	require.Eventually(t, func() bool {
+		return health() == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, leaderelection.ErrRenewStalled, e.Healthy())
+
+	cancel()
+	<-stopped
+	assert.Equal(t, float64(0), isLeader(t, registry))
+	assert.Equal(t, http.StatusOK, health())
+}
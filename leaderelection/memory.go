@@ -0,0 +1,289 @@
+package leaderelection
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps elections in process, backends created from the same
+// store compete with each other. It is mainly used for testing
+type MemoryStore struct {
+	lock       sync.Mutex
+	records    map[string]*memoryRecord
+	candidates map[string]map[string]int
+	nominees   map[string]memoryNominee
+}
+
+type memoryNominee struct {
+	identity string
+	expireAt time.Time
+}
+
+type memoryRecord struct {
+	holder   string
+	expireAt time.Time
+	revision int64
+	// changed is closed and replaced on every change of the record
+	changed chan struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records:    make(map[string]*memoryRecord),
+		candidates: make(map[string]map[string]int),
+		nominees:   make(map[string]memoryNominee),
+	}
+}
+
+// Backend returns a LockBackend of election key, leadership expires once not renewed in ttl
+func (s *MemoryStore) Backend(key string, ttl time.Duration) *MemoryBackend {
+	return &MemoryBackend{
+		store: s,
+		key:   key,
+		ttl:   ttl,
+	}
+}
+
+func (s *MemoryStore) record(key string) *memoryRecord {
+	r, ok := s.records[key]
+	if !ok {
+		r = &memoryRecord{changed: make(chan struct{})}
+		s.records[key] = r
+	}
+	if r.holder != "" && !r.expireAt.After(time.Now()) {
+		r.holder = ""
+		r.notify()
+	}
+	return r
+}
+
+func (r *memoryRecord) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+var (
+	_ LockBackend = new(MemoryBackend)
+	_ Candidacy   = new(MemoryBackend)
+)
+
+// MemoryBackend is a LockBackend of MemoryStore
+type MemoryBackend struct {
+	store    *MemoryStore
+	key      string
+	ttl      time.Duration
+	lock     sync.Mutex
+	identity string
+	revision int64
+}
+
+func (b *MemoryBackend) Campaign(ctx context.Context, identity string) error {
+	for {
+		b.store.lock.Lock()
+		r := b.store.record(b.key)
+		if r.holder == "" {
+			r.holder = identity
+			r.expireAt = time.Now().Add(b.ttl)
+			r.revision++
+			r.notify()
+			b.lock.Lock()
+			b.identity, b.revision = identity, r.revision
+			b.lock.Unlock()
+			b.store.lock.Unlock()
+			return nil
+		}
+		changed, expireAt := r.changed, r.expireAt
+		b.store.lock.Unlock()
+
+		timer := time.NewTimer(time.Until(expireAt))
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+// owned returns the record if it's held by the last campaign, store lock should be held
+func (b *MemoryBackend) owned() (*memoryRecord, bool) {
+	b.lock.Lock()
+	identity, revision := b.identity, b.revision
+	b.lock.Unlock()
+	r := b.store.record(b.key)
+	return r, identity != "" && r.holder == identity && r.revision == revision
+}
+
+func (b *MemoryBackend) Token() int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.revision
+}
+
+func (b *MemoryBackend) Renew(ctx context.Context) error {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	r, ok := b.owned()
+	if !ok {
+		return ErrLeadershipLost
+	}
+	r.expireAt = time.Now().Add(b.ttl)
+	return nil
+}
+
+func (b *MemoryBackend) Resign(ctx context.Context) error {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	if r, ok := b.owned(); ok {
+		r.holder = ""
+		r.notify()
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Lost(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+	go func() {
+		for {
+			b.store.lock.Lock()
+			r, ok := b.owned()
+			changed, expireAt := r.changed, r.expireAt
+			b.store.lock.Unlock()
+			if !ok {
+				close(lost)
+				return
+			}
+			timer := time.NewTimer(time.Until(expireAt))
+			select {
+			case <-changed:
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return lost
+}
+
+func (b *MemoryBackend) Leader(ctx context.Context) (string, error) {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	r := b.store.record(b.key)
+	if r.holder == "" {
+		return "", ErrNonLeaderElected
+	}
+	return r.holder, nil
+}
+
+func (b *MemoryBackend) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		var last string
+		for {
+			b.store.lock.Lock()
+			r := b.store.record(b.key)
+			leader, changed, expireAt := r.holder, r.changed, r.expireAt
+			b.store.lock.Unlock()
+
+			if leader != "" && leader != last {
+				last = leader
+				select {
+				case ch <- leader:
+				case <-ctx.Done():
+					return
+				}
+			}
+			var (
+				timer  *time.Timer
+				expiry <-chan time.Time
+			)
+			if leader != "" {
+				timer = time.NewTimer(time.Until(expireAt))
+				expiry = timer.C
+			}
+			select {
+			case <-changed:
+			case <-expiry:
+			case <-ctx.Done():
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (b *MemoryBackend) Register(ctx context.Context, c Candidate) error {
+	b.store.lock.Lock()
+	candidates, ok := b.store.candidates[b.key]
+	if !ok {
+		candidates = make(map[string]int)
+		b.store.candidates[b.key] = candidates
+	}
+	candidates[c.Identity] = c.Priority
+	b.store.lock.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.store.lock.Lock()
+		delete(candidates, c.Identity)
+		b.store.lock.Unlock()
+	}()
+	return nil
+}
+
+func (b *MemoryBackend) Candidates(ctx context.Context) ([]Candidate, error) {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	var candidates []Candidate
+	for identity, priority := range b.store.candidates[b.key] {
+		candidates = append(candidates, Candidate{Identity: identity, Priority: priority})
+	}
+	return candidates, nil
+}
+
+func (b *MemoryBackend) Nominate(ctx context.Context, identity string, ttl time.Duration) error {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	if identity == "" {
+		delete(b.store.nominees, b.key)
+		return nil
+	}
+	b.store.nominees[b.key] = memoryNominee{identity: identity, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (b *MemoryBackend) Nominee(ctx context.Context) (string, error) {
+	b.store.lock.Lock()
+	defer b.store.lock.Unlock()
+	nominee, ok := b.store.nominees[b.key]
+	if !ok || !nominee.expireAt.After(time.Now()) {
+		return "", nil
+	}
+	return nominee.identity, nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return b.Resign(context.Background())
+}
+
+// Expire drops the leadership of key immediately as if it's not renewed in time
+func (s *MemoryStore) Expire(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := s.record(key)
+	if r.holder != "" {
+		r.holder = ""
+		r.notify()
+	}
+}
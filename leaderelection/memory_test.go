@@ -0,0 +1,54 @@
+package leaderelection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	a := store.Backend("/election/test", time.Second)
+	b := store.Backend("/election/test", time.Second)
+	ctx := context.Background()
+
+	_, err := a.Leader(ctx)
+	assert.Equal(t, leaderelection.ErrNonLeaderElected, err)
+
+	observeCtx, observeCancel := context.WithCancel(ctx)
+	defer observeCancel()
+	observed := b.Observe(observeCtx)
+
+	require.NoError(t, a.Campaign(ctx, "a"))
+	assert.Equal(t, "a", <-observed)
+	require.NoError(t, a.Renew(ctx))
+	leader, err := b.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", leader)
+
+	// b blocks until a resigns
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, b.Campaign(timeoutCtx, "b"))
+	cancel()
+	assert.Equal(t, leaderelection.ErrLeadershipLost, b.Renew(ctx))
+
+	campaigned := make(chan error)
+	go func() {
+		campaigned <- b.Campaign(ctx, "b")
+	}()
+	require.NoError(t, a.Resign(ctx))
+	require.NoError(t, <-campaigned)
+	assert.Equal(t, "b", <-observed)
+	assert.Equal(t, leaderelection.ErrLeadershipLost, a.Renew(ctx))
+
+	// Expired leadership can't be renewed
+	store.Expire("/election/test")
+	assert.Equal(t, leaderelection.ErrLeadershipLost, b.Renew(ctx))
+	require.NoError(t, a.Campaign(ctx, "a"))
+	assert.Equal(t, "a", <-observed)
+}
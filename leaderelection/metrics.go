@@ -0,0 +1,91 @@
+package leaderelection
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultMetricsNamespace = "leader_election"
+)
+
+var metricsLabelNames = []string{"group", "identity"}
+
+// Metrics exports election events as prometheus metrics, feed it by Callbacks.OnEvent
+type Metrics struct {
+	isLeader         *prometheus.GaugeVec
+	transitions      *prometheus.CounterVec
+	campaignFailures *prometheus.CounterVec
+	renewFailures    *prometheus.CounterVec
+	lastRenew        *prometheus.GaugeVec
+}
+
+// NewMetrics registers metrics to registry, prometheus default registry is used if nil
+func NewMetrics(namespace string, registry prometheus.Registerer) *Metrics {
+	if namespace == "" {
+		namespace = DefaultMetricsNamespace
+	}
+	m := &Metrics{
+		isLeader: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "is_leader",
+			Help:      "Whether the instance is leading the group.",
+		}, metricsLabelNames),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transitions_total",
+			Help:      "Times of leadership acquired or stopped.",
+		}, []string{"group", "identity", "type"}),
+		campaignFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "campaign_failures_total",
+			Help:      "Failed campaigns apart from timeouts.",
+		}, metricsLabelNames),
+		renewFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "renew_failures_total",
+			Help:      "Failed renewals of leadership.",
+		}, metricsLabelNames),
+		lastRenew: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_renew_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful acquisition or renewal.",
+		}, metricsLabelNames),
+	}
+	cs := []prometheus.Collector{m.isLeader, m.transitions, m.campaignFailures, m.renewFailures, m.lastRenew}
+	if registry == nil {
+		prometheus.MustRegister(cs...)
+	} else {
+		registry.MustRegister(cs...)
+	}
+	return m
+}
+
+func (m *Metrics) OnEvent(e Event) {
+	switch e.Type {
+	case EventAcquired:
+		m.isLeader.WithLabelValues(e.Group, e.Identity).Set(1)
+		m.transitions.WithLabelValues(e.Group, e.Identity, string(e.Type)).Inc()
+		m.lastRenew.WithLabelValues(e.Group, e.Identity).Set(float64(e.AcquireTime.UnixNano()) / 1e9)
+	case EventRenewed:
+		m.lastRenew.WithLabelValues(e.Group, e.Identity).Set(float64(e.RenewTime.UnixNano()) / 1e9)
+	case EventStopped:
+		m.isLeader.WithLabelValues(e.Group, e.Identity).Set(0)
+		m.transitions.WithLabelValues(e.Group, e.Identity, string(e.Type)).Inc()
+	case EventCampaignFailed:
+		m.campaignFailures.WithLabelValues(e.Group, e.Identity).Inc()
+	case EventRenewFailed:
+		m.renewFailures.WithLabelValues(e.Group, e.Identity).Inc()
+	}
+}
+
+// Callbacks returns cb with OnEvent feeding metrics before calling the original one
+func (m *Metrics) Callbacks(cb Callbacks) Callbacks {
+	onEvent := cb.OnEvent
+	cb.OnEvent = func(e Event) {
+		m.OnEvent(e)
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+	return cb
+}
@@ -0,0 +1,253 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrLocked    = errors.New("already locked")
+	ErrNotLocked = errors.New("not locked")
+	ErrNoPermits = errors.New("semaphore size should be positive")
+	ErrClosed    = errors.New("closed")
+)
+
+// Mutex is a distributed lock of Group, which is an election campaigned on
+// demand. The lock is kept by renewals as leadership is, so it's released
+// once the holder is gone for LeaseDuration. Callbacks are triggered as the
+// election does. A Mutex is a single participant, concurrent Lock calls on
+// the same Mutex are serialized and fail with ErrLocked while it's held
+type Mutex struct {
+	elector *Elector
+	// acquiring serializes Lock calls
+	acquiring sync.Mutex
+	lock      sync.Mutex
+	cancel    context.CancelFunc
+	done      chan struct{}
+	reason    error
+}
+
+// NewMutex creates a Mutex of c as New does, ReCampaign and Priority are ignored
+func NewMutex(c Config) (*Mutex, error) {
+	c.ReCampaign = false
+	c.Priority = 0
+	e, err := New(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Mutex{elector: e}, nil
+}
+
+// Lock blocks until the lock acquired, ctx is done or closed. The returned context is
+// canceled once unlocked or the lock lost, and carries the fencing token
+func (m *Mutex) Lock(ctx context.Context) (context.Context, error) {
+	m.acquiring.Lock()
+	defer m.acquiring.Unlock()
+	m.lock.Lock()
+	held := m.done != nil
+	m.lock.Unlock()
+	if held {
+		return nil, ErrLocked
+	}
+
+	acquireCtx, cancel := m.elector.releasable(ctx)
+	acquired := m.elector.acquire(acquireCtx)
+	cancel()
+	if !acquired {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrClosed
+	}
+
+	heldCtx, cancel := m.elector.releasable(context.Background())
+	lockedCh := make(chan context.Context, 1)
+	done := make(chan struct{})
+	m.lock.Lock()
+	m.cancel, m.done, m.reason = cancel, done, nil
+	m.lock.Unlock()
+	go func() {
+		defer close(done)
+		reason := m.elector.lead(heldCtx, func(leadingCtx context.Context) {
+			lockedCh <- leadingCtx
+		})
+		m.lock.Lock()
+		m.reason = reason
+		m.lock.Unlock()
+	}()
+	return <-lockedCh, nil
+}
+
+// Unlock releases the lock, ErrLeadershipLost is returned if it was lost before
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.lock.Lock()
+	cancel, done := m.cancel, m.done
+	m.lock.Unlock()
+	if done == nil {
+		return ErrNotLocked
+	}
+	cancel()
+	<-done
+	m.elector.resign(ctx)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	reason := m.reason
+	m.cancel, m.done, m.reason = nil, nil, nil
+	if reason == ErrLeadershipLost {
+		return reason
+	}
+	return nil
+}
+
+// Holder returns identity of the lock holder, ErrNonLeaderElected if unlocked
+func (m *Mutex) Holder() (string, error) {
+	return m.elector.GetLeader()
+}
+
+// Close unlocks and releases the backend if it's created by NewMutex
+func (m *Mutex) Close(ctx context.Context) {
+	m.Unlock(ctx)
+	m.elector.Release(ctx)
+}
+
+type SemaphoreConfig struct {
+	// Config is the template of slot mutexes, Backend is ignored
+	Config
+	// Size is the number of permits
+	Size int
+	// NewBackend creates the backend of slot key. Backends sharing a single
+	// etcd session of ETCDClient are used if nil
+	NewBackend func(key string) LockBackend
+}
+
+// Semaphore is a distributed counting semaphore of Size permits, each of which
+// is a Mutex of Group with suffix ".<slot>". A Semaphore holds a permit at most,
+// just as a Mutex does
+type Semaphore struct {
+	config  SemaphoreConfig
+	session *EtcdSession
+	slots   []*Mutex
+	lock    sync.Mutex
+	held    *Mutex
+}
+
+func NewSemaphore(c SemaphoreConfig) (*Semaphore, error) {
+	if c.Size <= 0 {
+		return nil, ErrNoPermits
+	}
+	c.setDefaults()
+	newBackend, session, err := sharedBackends(c.NewBackend, c.ETCDClient, c.LeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+	s := &Semaphore{
+		config:  c,
+		session: session,
+		slots:   make([]*Mutex, 0, c.Size),
+	}
+	for i := 0; i < c.Size; i++ {
+		mc := c.Config
+		mc.Group = fmt.Sprintf("%s.%d", c.Group, i)
+		mc.Backend = newBackend(electionKey(c.Prefix, mc.Group))
+		m, err := NewMutex(mc)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.slots = append(s.slots, m)
+	}
+	return s, nil
+}
+
+// Acquire blocks until a permit acquired, ctx is done or closed. The returned context
+// is canceled once released or the permit lost, and carries the fencing token
+// of the slot
+func (s *Semaphore) Acquire(ctx context.Context) (context.Context, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.held != nil {
+		return nil, ErrLocked
+	}
+
+	type result struct {
+		slot    *Mutex
+		heldCtx context.Context
+	}
+	acquireCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, len(s.slots))
+	var wg sync.WaitGroup
+	for _, slot := range s.slots {
+		slot := slot
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			heldCtx, err := slot.Lock(acquireCtx)
+			if err == nil {
+				results <- result{slot: slot, heldCtx: heldCtx}
+			}
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	var acquired *result
+	select {
+	case r := <-results:
+		acquired = &r
+	case <-ctx.Done():
+	case <-finished:
+	}
+	cancel()
+	<-finished
+	close(results)
+	for r := range results {
+		if acquired == nil {
+			acquired = &r
+			continue
+		}
+		// Slots acquired concurrently are given back
+		r.slot.Unlock(context.Background())
+	}
+	if acquired == nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrClosed
+	}
+	s.held = acquired.slot
+	return acquired.heldCtx, nil
+}
+
+// Release gives back the permit, ErrLeadershipLost is returned if it was lost before
+func (s *Semaphore) Release(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.held == nil {
+		return ErrNotLocked
+	}
+	err := s.held.Unlock(ctx)
+	s.held = nil
+	return err
+}
+
+// Close releases the permit and backends of all slots
+func (s *Semaphore) Close(ctx context.Context) {
+	s.Release(ctx)
+	s.close()
+}
+
+func (s *Semaphore) close() {
+	for _, m := range s.slots {
+		m.elector.Release(context.Background())
+		m.elector.backend.Close()
+	}
+	if s.session != nil {
+		s.session.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package leaderelection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMutex(t *testing.T, store *leaderelection.MemoryStore, identity string) *leaderelection.Mutex {
+	m, err := leaderelection.NewMutex(leaderelection.Config{
+		LeaseDuration: time.Second,
+		RenewDeadline: 50 * time.Millisecond,
+		RetryPeriod:   10 * time.Millisecond,
+		Backend:       store.Backend("/election/migration", time.Second),
+		Group:         "migration",
+		Identity:      identity,
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestMutex(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx := context.Background()
+	a, b := newTestMutex(t, store, "a"), newTestMutex(t, store, "b")
+	defer a.Close(ctx)
+	defer b.Close(ctx)
+
+	assert.Equal(t, leaderelection.ErrNotLocked, a.Unlock(ctx))
+	lockedA, err := a.Lock(ctx)
+	require.NoError(t, err)
+	tokenA, ok := leaderelection.FencingToken(lockedA)
+	require.True(t, ok)
+	holder, err := b.Holder()
+	require.NoError(t, err)
+	assert.Equal(t, "a", holder)
+	_, err = a.Lock(ctx)
+	assert.Equal(t, leaderelection.ErrLocked, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = b.Lock(timeoutCtx)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	lockedB := make(chan context.Context)
+	go func() {
+		locked, err := b.Lock(ctx)
+		assert.NoError(t, err)
+		lockedB <- locked
+	}()
+	require.NoError(t, a.Unlock(ctx))
+	assert.Error(t, lockedA.Err())
+	locked := <-lockedB
+	tokenB, ok := leaderelection.FencingToken(locked)
+	require.True(t, ok)
+	assert.True(t, tokenB > tokenA)
+
+	// Lost lock cancels the context
+	store.Expire("/election/migration")
+	select {
+	case <-locked.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock context should be canceled once lost")
+	}
+	assert.Equal(t, leaderelection.ErrLeadershipLost, b.Unlock(ctx))
+}
+
+func TestSemaphore(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx := context.Background()
+	newSemaphore := func(identity string) *leaderelection.Semaphore {
+		s, err := leaderelection.NewSemaphore(leaderelection.SemaphoreConfig{
+			Config: leaderelection.Config{
+				LeaseDuration: time.Second,
+				RenewDeadline: 50 * time.Millisecond,
+				RetryPeriod:   10 * time.Millisecond,
+				Group:         "batch",
+				Identity:      identity,
+			},
+			Size: 2,
+			NewBackend: func(key string) leaderelection.LockBackend {
+				return store.Backend(key, time.Second)
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	a, b, c := newSemaphore("a"), newSemaphore("b"), newSemaphore("c")
+	defer a.Close(ctx)
+	defer b.Close(ctx)
+	defer c.Close(ctx)
+
+	_, err := a.Acquire(ctx)
+	require.NoError(t, err)
+	_, err = b.Acquire(ctx)
+	require.NoError(t, err)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = c.Acquire(timeoutCtx)
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		_, err := c.Acquire(ctx)
+		assert.NoError(t, err)
+		close(acquired)
+	}()
+	require.NoError(t, a.Release(ctx))
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("permit should be acquired once released")
+	}
+	assert.Equal(t, leaderelection.ErrNotLocked, a.Release(ctx))
+
+	_, err = leaderelection.NewSemaphore(leaderelection.SemaphoreConfig{})
+	assert.Equal(t, leaderelection.ErrNoPermits, err)
+}
+
+func TestCloseTwice(t *testing.T) {
+	store := leaderelection.NewMemoryStore()
+	ctx := context.Background()
+	m := newTestMutex(t, store, "a")
+	_, err := m.Lock(ctx)
+	require.NoError(t, err)
+	m.Close(ctx)
+	assert.NotPanics(t, func() { m.Close(ctx) })
+
+	s, err := leaderelection.NewSemaphore(leaderelection.SemaphoreConfig{
+		Config: leaderelection.Config{Group: "batch", Identity: "a"},
+		Size:   2,
+		NewBackend: func(key string) leaderelection.LockBackend {
+			return store.Backend(key, time.Second)
+		},
+	})
+	require.NoError(t, err)
+	s.Close(ctx)
+	assert.NotPanics(t, func() { s.Close(ctx) })
+
+	g := newTestGroupManager(t, store, "a", []string{"p0"}, leaderelection.GroupCallbacks{})
+	g.Release(ctx)
+	assert.NotPanics(t, func() { g.Release(ctx) })
+}
@@ -0,0 +1,279 @@
+package leaderelection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSQLTable = "leader_election"
+)
+
+var (
+	ErrInvalidTTL          = errors.New("TTL should be positive")
+	ErrInvalidPollInterval = errors.New("PollInterval should be positive")
+)
+
+// SQLBackendConfig configures SQLBackend. The table is expected as
+//
+//	CREATE TABLE leader_election (
+//		name       VARCHAR(255) NOT NULL PRIMARY KEY,
+//		holder     VARCHAR(255) NOT NULL,
+//		expires_at BIGINT       NOT NULL,
+//		revision   BIGINT       NOT NULL
+//	)
+//
+// expires_at is unix nanoseconds set by candidates, so clocks of them should be synchronized.
+// Statements use `?` placeholders
+type SQLBackendConfig struct {
+	DB *sql.DB
+	// Table defaults to DefaultSQLTable
+	Table string
+	// Key is the row name of election
+	Key string
+	// TTL of the leadership without renewal
+	TTL time.Duration
+	// PollInterval of campaign and observation, defaults to TTL/4
+	PollInterval time.Duration
+}
+
+var _ LockBackend = new(SQLBackend)
+
+// SQLBackend elects leader by compare-and-update a row with holder and expiry
+type SQLBackend struct {
+	config   SQLBackendConfig
+	lock     sync.Mutex
+	identity string
+	revision int64
+}
+
+func NewSQLBackend(c SQLBackendConfig) (*SQLBackend, error) {
+	if c.DB == nil {
+		return nil, ErrNoBackend
+	}
+	if c.TTL <= 0 {
+		return nil, ErrInvalidTTL
+	}
+	if c.Table == "" {
+		c.Table = DefaultSQLTable
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = c.TTL / 4
+	}
+	if c.PollInterval <= 0 {
+		return nil, ErrInvalidPollInterval
+	}
+	return &SQLBackend{config: c}, nil
+}
+
+func (b *SQLBackend) Campaign(ctx context.Context, identity string) error {
+	ticker := time.NewTicker(b.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		acquired, err := b.tryAcquire(ctx, identity)
+		if err != nil {
+			// Drivers may wrap the context error of an aborted statement
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if acquired {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *SQLBackend) tryAcquire(ctx context.Context, identity string) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(b.config.TTL).UnixNano()
+	result, err := b.config.DB.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET holder = ?, expires_at = ?, revision = revision + 1 "+
+			"WHERE name = ? AND (holder = ? OR holder = '' OR expires_at < ?)", b.config.Table),
+		identity, expiresAt, b.config.Key, identity, now.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		// The row held by others is left alone, only an absent one is inserted
+		_, _, exists, err := b.lookup(ctx)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return false, nil
+		}
+		_, err = b.config.DB.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (name, holder, expires_at, revision) VALUES (?, ?, ?, 1)", b.config.Table),
+			b.config.Key, identity, expiresAt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			// Row exists means others won the race of insertion
+			if _, _, exists, lookupErr := b.lookup(ctx); lookupErr == nil && exists {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	holder, revision, _, err := b.lookup(ctx)
+	if err != nil {
+		return false, err
+	}
+	if holder != identity {
+		return false, nil
+	}
+	b.lock.Lock()
+	b.identity, b.revision = identity, revision
+	b.lock.Unlock()
+	return true, nil
+}
+
+func (b *SQLBackend) lookup(ctx context.Context) (holder string, revision int64, exists bool, err error) {
+	var expiresAt int64
+	err = b.config.DB.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT holder, expires_at, revision FROM %s WHERE name = ?", b.config.Table),
+		b.config.Key).Scan(&holder, &expiresAt, &revision)
+	if err == sql.ErrNoRows {
+		return "", 0, false, nil
+	}
+	if err != nil {
+		return "", 0, false, err
+	}
+	if expiresAt < time.Now().UnixNano() {
+		holder = ""
+	}
+	return holder, revision, true, nil
+}
+
+func (b *SQLBackend) owner() (string, int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.identity, b.revision
+}
+
+// Token is the revision column of row, which increases on every acquisition
+func (b *SQLBackend) Token() int64 {
+	_, revision := b.owner()
+	return revision
+}
+
+func (b *SQLBackend) Renew(ctx context.Context) error {
+	identity, revision := b.owner()
+	if identity == "" {
+		return ErrLeadershipLost
+	}
+	now := time.Now()
+	result, err := b.config.DB.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET expires_at = ? WHERE name = ? AND holder = ? AND revision = ? AND expires_at >= ?", b.config.Table),
+		now.Add(b.config.TTL).UnixNano(), b.config.Key, identity, revision, now.UnixNano())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLeadershipLost
+	}
+	return nil
+}
+
+func (b *SQLBackend) Resign(ctx context.Context) error {
+	identity, revision := b.owner()
+	if identity == "" {
+		return nil
+	}
+	_, err := b.config.DB.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE %s SET holder = '', expires_at = 0 WHERE name = ? AND holder = ? AND revision = ?", b.config.Table),
+		b.config.Key, identity, revision)
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	b.identity = ""
+	b.lock.Unlock()
+	return nil
+}
+
+// Lost polls the row every PollInterval, query errors are ignored
+func (b *SQLBackend) Lost(ctx context.Context) <-chan struct{} {
+	lost := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(b.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			identity, revision := b.owner()
+			holder, currentRevision, _, err := b.lookup(ctx)
+			if identity == "" || (err == nil && (holder != identity || currentRevision != revision)) {
+				close(lost)
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lost
+}
+
+func (b *SQLBackend) Leader(ctx context.Context) (string, error) {
+	holder, _, _, err := b.lookup(ctx)
+	if err != nil {
+		return "", err
+	}
+	if holder == "" {
+		return "", ErrNonLeaderElected
+	}
+	return holder, nil
+}
+
+// Observe polls the row every PollInterval
+func (b *SQLBackend) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(b.config.PollInterval)
+		defer ticker.Stop()
+		var last string
+		for {
+			leader, err := b.Leader(ctx)
+			if err == nil && leader != last {
+				last = leader
+				select {
+				case ch <- leader:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Close resigns the leadership, the DB is left open
+func (b *SQLBackend) Close() error {
+	return b.Resign(context.Background())
+}
@@ -0,0 +1,99 @@
+package leaderelection_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/leaderelection"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file::memory:?cache=shared")
+	require.NoError(t, err)
+	// A single connection keeps the in-memory database alive and serializes writes
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`CREATE TABLE leader_election (
+		name       VARCHAR(255) NOT NULL PRIMARY KEY,
+		holder     VARCHAR(255) NOT NULL,
+		expires_at BIGINT       NOT NULL,
+		revision   BIGINT       NOT NULL
+	)`)
+	require.NoError(t, err)
+	return db
+}
+
+func TestSQLBackendConfig(t *testing.T) {
+	db := newTestSQLDB(t)
+	_, err := leaderelection.NewSQLBackend(leaderelection.SQLBackendConfig{DB: db, Key: "test"})
+	assert.Equal(t, leaderelection.ErrInvalidTTL, err)
+	_, err = leaderelection.NewSQLBackend(leaderelection.SQLBackendConfig{DB: db, Key: "test", TTL: 2})
+	assert.Equal(t, leaderelection.ErrInvalidPollInterval, err)
+	_, err = leaderelection.NewSQLBackend(leaderelection.SQLBackendConfig{Key: "test", TTL: time.Second})
+	assert.Equal(t, leaderelection.ErrNoBackend, err)
+}
+
+func TestSQLBackend(t *testing.T) {
+	db := newTestSQLDB(t)
+	newBackend := func() *leaderelection.SQLBackend {
+		b, err := leaderelection.NewSQLBackend(leaderelection.SQLBackendConfig{
+			DB:           db,
+			Key:          "test",
+			TTL:          200 * time.Millisecond,
+			PollInterval: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		return b
+	}
+	a, b := newBackend(), newBackend()
+	ctx := context.Background()
+
+	_, err := a.Leader(ctx)
+	assert.Equal(t, leaderelection.ErrNonLeaderElected, err)
+
+	// Acquire and renew
+	require.NoError(t, a.Campaign(ctx, "a"))
+	firstToken := a.Token()
+	assert.Equal(t, int64(1), firstToken)
+	require.NoError(t, a.Renew(ctx))
+	leader, err := b.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", leader)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, b.Campaign(timeoutCtx, "b"))
+	cancel()
+
+	// Take over once expired, the token increases and the old leader is fenced
+	lost := a.Lost(ctx)
+	time.Sleep(250 * time.Millisecond)
+	require.NoError(t, b.Campaign(ctx, "b"))
+	assert.True(t, b.Token() > firstToken)
+	assert.Equal(t, leaderelection.ErrLeadershipLost, a.Renew(ctx))
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("lost is not closed after taken over")
+	}
+
+	// Resign hands over immediately
+	campaigned := make(chan error, 1)
+	go func() {
+		campaigned <- a.Campaign(ctx, "a")
+	}()
+	require.NoError(t, b.Resign(ctx))
+	require.NoError(t, <-campaigned)
+	assert.True(t, a.Token() > b.Token())
+	leader, err = b.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a", leader)
+
+	require.NoError(t, a.Close())
+	_, err = b.Leader(ctx)
+	assert.Equal(t, leaderelection.ErrNonLeaderElected, err)
+}
@@ -0,0 +1,150 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	lokiLevelLabel             = "level"
+	defaultMaxLabelValues      = 100
+	defaultMaxLabelValueLength = 128
+)
+
+// LokiSender is implemented by loki.Loki. TrySend never blocks and is false
+// once the line is dropped, e.g. the queue is full or the sender is closed
+type LokiSender interface {
+	TrySend(at time.Time, labels map[string]string, line string) bool
+}
+
+// LokiOptionFunc configures Loki hook
+type LokiOptionFunc func(*Loki)
+
+// WithLabelFields promotes fields to stream labels, other fields stay in the line
+func WithLabelFields(fields ...string) LokiOptionFunc {
+	return func(l *Loki) {
+		for _, field := range fields {
+			l.labelFields[field] = struct{}{}
+		}
+	}
+}
+
+// WithLineFormatter formats the line, defaults to text without timestamp and colors
+func WithLineFormatter(formatter logrus.Formatter) LokiOptionFunc {
+	return func(l *Loki) {
+		l.formatter = formatter
+	}
+}
+
+// WithLabelLimits guards label cardinality. Once a label field has seen
+// maxValues distinct values, new values are kept in the line instead. Values
+// longer than maxValueLength are kept in the line as well
+func WithLabelLimits(maxValues, maxValueLength int) LokiOptionFunc {
+	return func(l *Loki) {
+		l.maxLabelValues = maxValues
+		l.maxLabelValueLength = maxValueLength
+	}
+}
+
+// Loki implements logrus.Hook, which ships entries to Loki with the level and
+// label fields as stream labels
+type Loki struct {
+	sender              LokiSender
+	level               logrus.Level
+	labelFields         map[string]struct{}
+	formatter           logrus.Formatter
+	maxLabelValues      int
+	maxLabelValueLength int
+	lock                sync.Mutex
+	// seen values of each label field
+	seen map[string]map[string]struct{}
+}
+
+// NewLoki ships entries of level or more severe to sender
+func NewLoki(sender LokiSender, level logrus.Level, opts ...LokiOptionFunc) *Loki {
+	l := &Loki{
+		sender:      sender,
+		level:       level,
+		labelFields: make(map[string]struct{}),
+		formatter: &logrus.TextFormatter{
+			DisableColors:    true,
+			DisableTimestamp: true,
+		},
+		maxLabelValues:      defaultMaxLabelValues,
+		maxLabelValueLength: defaultMaxLabelValueLength,
+		seen:                make(map[string]map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Fire implement logrus.Hook.Fire which is to send log. It never blocks
+// logging, lines are dropped by sender rather than waiting for room
+func (l *Loki) Fire(entry *logrus.Entry) error {
+	labels := map[string]string{
+		lokiLevelLabel: entry.Level.String(),
+	}
+	data := make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if _, ok := l.labelFields[key]; ok {
+			if v := fmt.Sprint(value); l.admitLabel(key, v) {
+				labels[key] = v
+				continue
+			}
+		}
+		data[key] = value
+	}
+
+	lineEntry := entry.Dup()
+	lineEntry.Data = data
+	lineEntry.Level = entry.Level
+	lineEntry.Message = entry.Message
+	lineEntry.Caller = entry.Caller
+	line, err := l.formatter.Format(lineEntry)
+	if err != nil {
+		return err
+	}
+	// Dropped lines are counted by sender, reporting them to logrus would
+	// print one more line for each
+	l.sender.TrySend(entry.Time, labels, string(bytes.TrimRight(line, "\n")))
+	return nil
+}
+
+// admitLabel reports whether value is allowed as label of field
+func (l *Loki) admitLabel(field, value string) bool {
+	if value == "" || len(value) > l.maxLabelValueLength {
+		return false
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	values, ok := l.seen[field]
+	if !ok {
+		values = make(map[string]struct{})
+		l.seen[field] = values
+	}
+	if _, ok = values[value]; ok {
+		return true
+	}
+	if len(values) >= l.maxLabelValues {
+		return false
+	}
+	values[value] = struct{}{}
+	return true
+}
+
+// Levels implements logrus.Hook.Levels which return level(s) should fire
+func (l *Loki) Levels() []logrus.Level {
+	levels := make([]logrus.Level, 0)
+	for _, level := range logrus.AllLevels {
+		if level <= l.level {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
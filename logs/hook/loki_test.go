@@ -0,0 +1,75 @@
+package hook_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/logs/hook"
+	"github.com/oif/gokit/loki"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sent struct {
+	labels map[string]string
+	line   string
+}
+
+type fakeSender struct {
+	sent []sent
+}
+
+func (s *fakeSender) TrySend(at time.Time, labels map[string]string, line string) bool {
+	s.sent = append(s.sent, sent{labels: labels, line: line})
+	return true
+}
+
+func TestLokiHook(t *testing.T) {
+	sender := new(fakeSender)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook.NewLoki(sender, logrus.InfoLevel,
+		hook.WithLabelFields("app", "user"),
+		hook.WithLabelLimits(1, 8)))
+
+	logger.Debug("filtered")
+	logger.WithFields(logrus.Fields{"app": "api", "user": "a", "latency": 3}).Info("first")
+	// user exceeds distinct values and app is too long, both stay in line
+	logger.WithFields(logrus.Fields{"app": "very-long-app", "user": "b"}).Warn("second")
+
+	require.Len(t, sender.sent, 2)
+	assert.Equal(t, map[string]string{"level": "info", "app": "api", "user": "a"}, sender.sent[0].labels)
+	assert.Equal(t, `level=info msg=first latency=3`, sender.sent[0].line)
+	assert.Equal(t, map[string]string{"level": "warning"}, sender.sent[1].labels)
+	assert.Equal(t, `level=warning msg=second app=very-long-app user=b`, sender.sent[1].line)
+}
+
+func TestLokiHookAfterSenderClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	sender, err := loki.NewLoki(server.URL, nil, loki.WithOverflow(loki.OverflowBlock, 0))
+	require.NoError(t, err)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook.NewLoki(sender, logrus.InfoLevel))
+	sender.Close()
+
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		logger.Info("after closed")
+	}()
+	select {
+	case <-logged:
+	case <-time.After(time.Second):
+		t.Fatal("logging should not block once sender closed")
+	}
+	assert.Equal(t, uint64(1), sender.Dropped())
+}
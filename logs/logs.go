@@ -58,6 +58,13 @@ func WithSourceHook() OptionFunc {
 	}
 }
 
+// WithLokiHook to ship logs of level or more severe to Loki, sender is usually a *loki.Loki
+func WithLokiHook(sender hook.LokiSender, level logrus.Level, opts ...hook.LokiOptionFunc) OptionFunc {
+	return func(l *logrus.Logger) {
+		l.Hooks.Add(hook.NewLoki(sender, level, opts...))
+	}
+}
+
 // Setup logger with options
 func Setup(opts ...OptionFunc) (*logrus.Logger, error) {
 	logger := logrus.New()
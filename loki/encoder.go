@@ -0,0 +1,174 @@
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+)
+
+// Encoding is the body format of push requests
+type Encoding int
+
+const (
+	// EncodingProtobuf is snappy compressed protobuf, the native format of Loki
+	EncodingProtobuf Encoding = iota
+	// EncodingJSON is the JSON push format, which is accepted by more gateways
+	EncodingJSON
+)
+
+const (
+	jsonContentType = "application/json"
+	gzipEncoding    = "gzip"
+)
+
+// WithEncoding selects body format of push requests, gzip applies to EncodingJSON only
+func WithEncoding(encoding Encoding, gzip bool) func(*Options) {
+	return func(o *Options) {
+		o.Encoding = encoding
+		o.Gzip = gzip
+	}
+}
+
+// encoder encodes push requests into body with content headers
+type encoder interface {
+	encode(req *PushRequest) ([]byte, error)
+	contentType() string
+	// contentEncoding is empty if not compressed by HTTP
+	contentEncoding() string
+}
+
+func newEncoder(encoding Encoding, gzip bool) encoder {
+	if encoding == EncodingJSON {
+		return &jsonEncoder{gzip: gzip}
+	}
+	return protobufEncoder{}
+}
+
+type protobufEncoder struct{}
+
+func (protobufEncoder) encode(req *PushRequest) ([]byte, error) {
+	buf, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, buf), nil
+}
+
+func (protobufEncoder) contentType() string {
+	return contentType
+}
+
+func (protobufEncoder) contentEncoding() string {
+	return ""
+}
+
+func decodeProtobuf(buf []byte) (*PushRequest, error) {
+	decoded, err := snappy.Decode(nil, buf)
+	if err != nil {
+		return nil, err
+	}
+	req := new(PushRequest)
+	if err = proto.Unmarshal(decoded, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+type jsonEncoder struct {
+	gzip bool
+}
+
+type jsonPushRequest struct {
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	// Values are tuples of timestamp in nanoseconds, line and optional structured metadata
+	Values [][]interface{} `json:"values"`
+}
+
+func (e *jsonEncoder) encode(req *PushRequest) ([]byte, error) {
+	jr := jsonPushRequest{Streams: make([]jsonStream, 0, len(req.Streams))}
+	for _, stream := range req.Streams {
+		labels, err := parseLabels(stream.Labels)
+		if err != nil {
+			return nil, err
+		}
+		js := jsonStream{
+			Stream: labels,
+			Values: make([][]interface{}, 0, len(stream.Entries)),
+		}
+		for _, e := range stream.Entries {
+			ts := time.Unix(e.Timestamp.GetSeconds(), int64(e.Timestamp.GetNanos())).UnixNano()
+			value := []interface{}{strconv.FormatInt(ts, 10), e.Line}
+			if len(e.StructuredMetadata) > 0 {
+				metadata := make(map[string]string, len(e.StructuredMetadata))
+				for _, pair := range e.StructuredMetadata {
+					metadata[pair.Name] = pair.Value
+				}
+				value = append(value, metadata)
+			}
+			js.Values = append(js.Values, value)
+		}
+		jr.Streams = append(jr.Streams, js)
+	}
+	buf, err := json.Marshal(jr)
+	if err != nil || !e.gzip {
+		return buf, err
+	}
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err = w.Write(buf); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func (e *jsonEncoder) contentType() string {
+	return jsonContentType
+}
+
+func (e *jsonEncoder) contentEncoding() string {
+	if e.gzip {
+		return gzipEncoding
+	}
+	return ""
+}
+
+// parseLabels parses labels formatted by model.LabelSet.String, e.g. {a="b", c="d"}
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("malformed labels %q", s)
+	}
+	rest := s[1 : len(s)-1]
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed labels %q", s)
+		}
+		name := rest[:eq]
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed labels %q: %w", s, err)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("malformed labels %q: %w", s, err)
+		}
+		labels[name] = value
+		rest = strings.TrimPrefix(rest[eq+1+len(quoted):], ", ")
+	}
+	return labels, nil
+}
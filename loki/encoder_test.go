@@ -0,0 +1,93 @@
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newCaptureServer(t *testing.T) (*httptest.Server, <-chan capturedRequest) {
+	requests := make(chan capturedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, postPath, r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- capturedRequest{header: r.Header, body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return server, requests
+}
+
+func TestJSONEncoding(t *testing.T) {
+	server, requests := newCaptureServer(t)
+	defer server.Close()
+
+	l, err := NewLokiCustomHostname(server.URL, "test", nil, WithEncoding(EncodingJSON, true))
+	require.NoError(t, err)
+	at := time.Unix(1700000000, 123)
+	l.SendWithMetadata(at, map[string]string{"app": `quoted "api"`}, map[string]string{"trace_id": "abc"}, "first")
+	l.Send(at.Add(time.Second), map[string]string{"app": `quoted "api"`}, "second")
+	l.Close()
+
+	r := <-requests
+	assert.Equal(t, jsonContentType, r.header.Get("Content-Type"))
+	assert.Equal(t, gzipEncoding, r.header.Get("Content-Encoding"))
+	gr, err := gzip.NewReader(bytes.NewReader(r.body))
+	require.NoError(t, err)
+	body, err := io.ReadAll(gr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"streams":[{
+		"stream":{"app":"quoted \"api\""},
+		"values":[
+			["1700000000000000123","first",{"trace_id":"abc"}],
+			["1700000001000000123","second"]
+		]}]}`, string(body))
+	assert.True(t, json.Valid(body))
+}
+
+func TestProtobufMetadata(t *testing.T) {
+	server, requests := newCaptureServer(t)
+	defer server.Close()
+
+	l, err := NewLokiCustomHostname(server.URL, "test", nil)
+	require.NoError(t, err)
+	l.SendWithMetadata(time.Now(), map[string]string{"app": "api"}, map[string]string{"trace_id": "abc"}, "first")
+	l.Close()
+
+	r := <-requests
+	assert.Equal(t, contentType, r.header.Get("Content-Type"))
+	req, err := decodeProtobuf(r.body)
+	require.NoError(t, err)
+	require.Len(t, req.Streams, 1)
+	assert.Equal(t, `{app="api"}`, req.Streams[0].Labels)
+	require.Len(t, req.Streams[0].Entries, 1)
+	e := req.Streams[0].Entries[0]
+	assert.Equal(t, "first", e.Line)
+	require.Len(t, e.StructuredMetadata, 1)
+	assert.Equal(t, "trace_id", e.StructuredMetadata[0].Name)
+	assert.Equal(t, "abc", e.StructuredMetadata[0].Value)
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels(`{a="b", c="d, e=\"f\""}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "b", "c": `d, e="f"`}, labels)
+	labels, err = parseLabels(`{}`)
+	require.NoError(t, err)
+	assert.Empty(t, labels)
+	_, err = parseLabels(`a="b"`)
+	assert.Error(t, err)
+}
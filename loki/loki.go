@@ -7,18 +7,18 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/timestamp"
-	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 )
 
@@ -28,15 +28,19 @@ const (
 	maxErrMsgLen = 1024
 )
 
+var ErrClosed = errors.New("loki client is closed")
+
 type entry struct {
 	labels model.LabelSet
 	*EntryAdapter
 }
 
 type payload struct {
-	at     time.Time
-	labels map[string]string
-	line   string
+	at       time.Time
+	labels   map[string]string
+	metadata map[string]string
+	line     string
+	tenant   string
 }
 
 type Loki struct {
@@ -47,21 +51,60 @@ type Loki struct {
 	payloadCh       chan payload
 	hostname        string
 	prependLabels   map[model.LabelName]model.LabelValue
+	labelsLock      sync.RWMutex
 	wg              sync.WaitGroup
 	username        string
 	password        string
 	customHeader    map[string]string
 	lokiClient      *http.Client
-	honorOriginTime bool
+	outOfOrder      OutOfOrderPolicy
+	spool           *spool
+	overflowPolicy  OverflowPolicy
+	overflowTimeout time.Duration
+	dropped         atomic.Uint64
+	errorHandler    func(err error)
+	metrics         *metrics
+	encoder         encoder
+	retryPolicy     RetryPolicy
+	tenant          string
+	tenantLabel     string
+	routes          []Route
+	// ctx aborts sending and backoff once Shutdown is timed out
+	ctx       context.Context
+	cancel    context.CancelFunc
+	flushCh   chan chan error
+	closeOnce sync.Once
+	// closing is closed once Shutdown started, payloadCh is closed afterwards
+	// with sendLock held so that no line is sent to it
+	closing  chan struct{}
+	sendLock sync.RWMutex
+	// done is closed once run exits
+	done chan struct{}
 }
 
 type Options struct {
-	BatchSize       int // send message lines in one streams
-	BatchWait       int
-	Username        string
-	Password        string
-	LokiTimeout     int  // always make sure calling loki api can be timed out
-	HonorOriginTime bool // keep the message time as is rather than changing to current even though messages lagging behind
+	BatchSize          int // send message lines in one streams
+	BatchWait          int
+	Username           string
+	Password           string
+	LokiTimeout        int              // always make sure calling loki api can be timed out
+	HonorOriginTime    bool             // keep the message time as is rather than changing to current even though messages lagging behind
+	OutOfOrderPolicy   OutOfOrderPolicy // what happens to lines older than the last one of their stream, ignored if HonorOriginTime
+	SpoolDir           string           // persist batches here before sending, unsent ones are replayed in order. Disabled if empty
+	SpoolMaxBytes      int64            // oldest batches are evicted once spool grows beyond it
+	OverflowPolicy     OverflowPolicy   // what Send does when the queue is full, blocks by default
+	OverflowTimeout    time.Duration    // how long Send blocks at most with OverflowBlockTimeout
+	ErrorHandler       func(err error)  // receives errors of sending, which are printed to stderr by default
+	EnableMetrics      bool
+	MetricsNamespace   string
+	MetricsConstLabels prometheus.Labels
+	MetricsRegistry    prometheus.Registerer
+	Encoding           Encoding // body format of push requests, protobuf by default
+	Gzip               bool     // compress JSON body by gzip
+	RetryPolicy        RetryPolicy
+	Tenant             string  // X-Scope-OrgID of lines without their own tenant
+	TenantLabel        string  // label whose value is taken as tenant of the line
+	Routes             []Route // streams are sent to URL of the first matched route
 }
 
 func WithBatch(batchSize, batchWait int) func(*Options) {
@@ -86,6 +129,25 @@ func WithOthers(lokiTimeout int, honorOriginTime bool) func(*Options) {
 	}
 }
 
+// WithErrorHandler receives errors of sending instead of printing them to stderr
+func WithErrorHandler(handler func(err error)) func(*Options) {
+	return func(o *Options) {
+		o.ErrorHandler = handler
+	}
+}
+
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "%v ERROR: %v\n", time.Now(), err)
+}
+
+// WithSpool persists batches in dir until sent, so they survive Loki outages and restarts
+func WithSpool(dir string, maxBytes int64) func(*Options) {
+	return func(o *Options) {
+		o.SpoolDir = dir
+		o.SpoolMaxBytes = maxBytes
+	}
+}
+
 func NewLoki(URL string, customHeader map[string]string, opts ...func(*Options)) (*Loki, error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -95,7 +157,7 @@ func NewLoki(URL string, customHeader map[string]string, opts ...func(*Options))
 }
 
 func NewLokiCustomHostname(URL, hostname string, customHeader map[string]string, opts ...func(*Options)) (*Loki, error) {
-
+	var err error
 	options := &Options{
 		BatchSize:       1000,
 		BatchWait:       10,
@@ -103,6 +165,11 @@ func NewLokiCustomHostname(URL, hostname string, customHeader map[string]string,
 		Password:        "",
 		LokiTimeout:     10,
 		HonorOriginTime: false,
+		SpoolMaxBytes:   128 << 20,
+		OverflowPolicy:  OverflowBlock,
+		OverflowTimeout: time.Second,
+		ErrorHandler:    printError,
+		RetryPolicy:     DefaultRetryPolicy,
 	}
 
 	for _, opt := range opts {
@@ -122,204 +189,412 @@ func NewLokiCustomHostname(URL, hostname string, customHeader map[string]string,
 		lokiClient: &http.Client{
 			Timeout: time.Duration(options.LokiTimeout) * time.Second,
 		},
-		honorOriginTime: options.HonorOriginTime,
+		outOfOrder:      options.OutOfOrderPolicy,
+		overflowPolicy:  options.OverflowPolicy,
+		overflowTimeout: options.OverflowTimeout,
+		errorHandler:    options.ErrorHandler,
+		encoder:         newEncoder(options.Encoding, options.Gzip),
+		retryPolicy:     options.RetryPolicy,
+		tenant:          options.Tenant,
+		tenantLabel:     options.TenantLabel,
+		flushCh:         make(chan chan error),
+		closing:         make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	if l.errorHandler == nil {
+		l.errorHandler = printError
+	}
+	if options.HonorOriginTime {
+		l.outOfOrder = OutOfOrderKeep
+	}
+	if l.retryPolicy.MaxAttempts < 1 {
+		l.retryPolicy.MaxAttempts = 1
+	}
+	if options.EnableMetrics {
+		if l.metrics, err = newMetrics(l, options.MetricsNamespace, options.MetricsConstLabels, options.MetricsRegistry); err != nil {
+			return nil, err
+		}
 	}
 
-	u, err := url.Parse(l.lokiURL)
-	if err != nil {
+	if options.SpoolDir != "" {
+		l.spool, err = openSpool(options.SpoolDir, options.SpoolMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if l.lokiURL, err = pushURL(l.lokiURL); err != nil {
 		return nil, err
 	}
-	if !strings.Contains(u.Path, postPath) {
-		u.Path = postPath
-		q := u.Query()
-		u.RawQuery = q.Encode()
-		l.lokiURL = u.String()
+	for _, route := range options.Routes {
+		if route.URL, err = pushURL(route.URL); err != nil {
+			return nil, err
+		}
+		l.routes = append(l.routes, route)
 	}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
 	l.wg.Add(1)
 	go l.run()
 	return l, nil
 }
 
+// Close sends queued lines and waits until done, see Shutdown
 func (l *Loki) Close() {
-	close(l.payloadCh)
-	l.wg.Wait()
+	l.Shutdown(context.Background())
 }
 
-func (l *Loki) AddPrependLabel(key, value string) {
-	l.prependLabels[model.LabelName(key)] = model.LabelValue(value)
+// Shutdown stops accepting lines and sends the queued ones. If ctx is done
+// before that, sending is aborted and ctx.Err() is returned, unsent batches are
+// kept in spool if enabled. Lines sent after Shutdown are dropped
+func (l *Loki) Shutdown(ctx context.Context) error {
+	l.closeOnce.Do(func() {
+		close(l.closing)
+		l.sendLock.Lock()
+		close(l.payloadCh)
+		l.sendLock.Unlock()
+	})
+	select {
+	case <-l.done:
+		l.wg.Wait()
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
 }
 
-func (l *Loki) Send(at time.Time, labels map[string]string, line string) {
-	l.payloadCh <- payload{
-		at:     at,
-		labels: labels,
-		line:   line,
+// Flush sends lines queued before it and blocks until they're sent or failed,
+// ctx only bounds the waiting
+func (l *Loki) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case l.flushCh <- reply:
+	case <-l.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 func (l *Loki) run() {
 	var (
-		curPktTime  time.Time
-		lastPktTime time.Time
-		maxWait     = time.NewTimer(l.batchWait)
-		batch       = map[model.Fingerprint]*StreamAdapter{}
-		batchSize   = 0
+		maxWait = time.NewTimer(l.batchWait)
+		batches = map[destination]*pendingBatch{}
+		order   = streamOrder{}
 	)
 	defer l.wg.Done()
+	defer close(l.done)
+	defer l.cancel()
 
 	defer func() {
-		if err := l.sendBatch(batch); err != nil {
-			fmt.Fprintf(os.Stderr, "%v ERROR: loki flush: %v\n", time.Now(), err)
+		if err := l.sendBatches(batches); err != nil {
+			l.errorHandler(fmt.Errorf("loki flush: %w", err))
 		}
 	}()
 
-	for {
-		select {
-		case p, ok := <-l.payloadCh:
-			if !ok {
-				return
-			}
-			curPktTime = p.at
-			// guard against entry out of order errors
-			if !l.honorOriginTime && lastPktTime.After(curPktTime) {
-				curPktTime = time.Now()
-			}
-			lastPktTime = curPktTime
+	add := func(p payload) {
+		labels := model.LabelSet{}
+		for key, value := range p.labels {
+			labels[model.LabelName(key)] = model.LabelValue(value)
+		}
+		l.addPrependLabels(labels)
+		dest := l.destinationOf(p, labels)
+		fp := labels.FastFingerprint()
+
+		// guard against entry out of order errors
+		tsNano := order.next(streamKey{dest: dest, fp: fp}, p.at, l.outOfOrder).UnixNano()
+		ts := &timestamp.Timestamp{
+			Seconds: tsNano / int64(time.Second),
+			Nanos:   int32(tsNano % int64(time.Second)),
+		}
 
-			tsNano := curPktTime.UnixNano()
-			ts := &timestamp.Timestamp{
-				Seconds: tsNano / int64(time.Second),
-				Nanos:   int32(tsNano % int64(time.Second)),
+		l.entry = entry{labels, &EntryAdapter{Timestamp: ts}}
+		l.entry.EntryAdapter.Line = p.line
+		entrySize := len(l.entry.Line)
+		if len(p.metadata) > 0 {
+			names := make([]string, 0, len(p.metadata))
+			for name := range p.metadata {
+				names = append(names, name)
 			}
-
-			l.entry = entry{model.LabelSet{}, &EntryAdapter{Timestamp: ts}}
-			for key, value := range p.labels {
-				l.entry.labels[model.LabelName(key)] = model.LabelValue(value)
+			sort.Strings(names)
+			for _, name := range names {
+				l.entry.StructuredMetadata = append(l.entry.StructuredMetadata,
+					&LabelPairAdapter{Name: name, Value: p.metadata[name]})
+				entrySize += len(name) + len(p.metadata[name])
 			}
-			for key, value := range l.prependLabels {
-				l.entry.labels[key] = value
+		}
+
+		batch, ok := batches[dest]
+		if !ok {
+			batch = &pendingBatch{streams: map[model.Fingerprint]*StreamAdapter{}}
+			batches[dest] = batch
+		}
+		// Batches of other destinations keep waiting for maxWait
+		if batch.size+entrySize > l.batchSize {
+			if err := l.sendBatches(map[destination]*pendingBatch{dest: batch}); err != nil {
+				l.errorHandler(fmt.Errorf("send size batch: %w", err))
 			}
-			l.entry.EntryAdapter.Line = p.line
+			batch.size = 0
+			batch.streams = map[model.Fingerprint]*StreamAdapter{}
+		}
 
-			if batchSize+len(l.entry.Line) > l.batchSize {
-				if err := l.sendBatch(batch); err != nil {
-					fmt.Fprintf(os.Stderr, "%v ERROR: send size batch: %v\n", lastPktTime, err)
-				}
-				batchSize = 0
-				batch = map[model.Fingerprint]*StreamAdapter{}
-				maxWait.Reset(l.batchWait)
+		batch.size += entrySize
+		stream, ok := batch.streams[fp]
+		if !ok {
+			stream = &StreamAdapter{
+				Labels: l.entry.labels.String(),
 			}
+			batch.streams[fp] = stream
+		}
+		stream.Entries = append(stream.Entries, l.EntryAdapter)
+	}
 
-			batchSize += len(l.entry.Line)
-			fp := l.entry.labels.FastFingerprint()
-			stream, ok := batch[fp]
+	for {
+		select {
+		case p, ok := <-l.payloadCh:
 			if !ok {
-				stream = &StreamAdapter{
-					Labels: l.entry.labels.String(),
+				return
+			}
+			add(p)
+
+		case reply := <-l.flushCh:
+			// Lines queued before Flush are taken into the batch
+			closed := false
+			for queued := len(l.payloadCh); queued > 0 && !closed; queued-- {
+				p, ok := <-l.payloadCh
+				if ok {
+					add(p)
 				}
-				batch[fp] = stream
+				closed = !ok
+			}
+			reply <- l.sendBatches(batches)
+			batches = map[destination]*pendingBatch{}
+			maxWait.Reset(l.batchWait)
+			if closed {
+				return
 			}
-			stream.Entries = append(stream.Entries, l.EntryAdapter)
 
 		case <-maxWait.C:
-			if len(batch) > 0 {
-				if err := l.sendBatch(batch); err != nil {
-					fmt.Fprintf(os.Stderr, "%v ERROR: send time batch: %v\n", lastPktTime, err)
+			if len(batches) > 0 {
+				if err := l.sendBatches(batches); err != nil {
+					l.errorHandler(fmt.Errorf("send time batch: %w", err))
+				}
+				batches = map[destination]*pendingBatch{}
+			} else if l.spool != nil {
+				if err := l.replaySpool(); err != nil {
+					l.errorHandler(fmt.Errorf("replay spool: %w", err))
 				}
-				batchSize = 0
-				batch = map[model.Fingerprint]*StreamAdapter{}
 			}
+			order.prune()
 			maxWait.Reset(l.batchWait)
 		}
 	}
 }
 
-func (l *Loki) sendBatch(batch map[model.Fingerprint]*StreamAdapter) error {
-	buf, err := encodeBatch(batch)
+// sendBatches sends batches of each destination, and replays spool if enabled.
+// The first error is returned, others are handled by errorHandler
+func (l *Loki) sendBatches(batches map[destination]*pendingBatch) error {
+	var firstErr error
+	for _, dest := range sortedDestinations(batches) {
+		if len(batches[dest].streams) == 0 {
+			continue
+		}
+		if err := l.sendBatch(dest, batches[dest].streams); err != nil {
+			if firstErr != nil {
+				l.errorHandler(err)
+				continue
+			}
+			firstErr = err
+		}
+	}
+	if l.spool != nil {
+		if err := l.replaySpool(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// sendBatch sends batch directly, or pushes it to spool if enabled
+func (l *Loki) sendBatch(dest destination, batch map[model.Fingerprint]*StreamAdapter) error {
+	if l.outOfOrder == OutOfOrderSort {
+		sortEntries(batch)
+	}
+	req := buildRequest(batch)
+	lines := countLines(req)
+	if l.spool == nil {
+		body, err := l.encoder.encode(req)
+		if err != nil {
+			return fmt.Errorf("encode batch error: %w", err)
+		}
+		return l.sendWithRetry(l.ctx, dest, body, lines)
+	}
+	// Spooled batches are always protobuf, and encoded by encoder on sending
+	buf, err := protobufEncoder{}.encode(req)
 	if err != nil {
 		return fmt.Errorf("encode batch error: %w", err)
 	}
+	evicted, err := l.spool.push(encodeSpooled(dest, buf))
+	if err != nil {
+		// Spool is unavailable, try to send it anyway
+		l.errorHandler(fmt.Errorf("spool batch: %w", err))
+		body, err := l.encoder.encode(req)
+		if err != nil {
+			return fmt.Errorf("encode batch error: %w", err)
+		}
+		return l.sendWithRetry(l.ctx, dest, body, lines)
+	}
+	if len(evicted) > 0 {
+		lines := 0
+		for _, buf := range evicted {
+			lines += spooledLines(buf)
+		}
+		l.dropped.Add(uint64(lines))
+		l.errorHandler(fmt.Errorf("spool is full, %d oldest batches of %d lines evicted", len(evicted), lines))
+	}
+	return nil
+}
 
-	// 重试相关配置
-	maxRetries := 3
-	backoffBase := time.Second
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// 创建新的context，每次重试都有完整的超时时间
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-
-		statusCode, err := l.send(ctx, buf)
-		cancel() // 立即释放context资源
+// spooledLines counts lines of the spooled batch, 0 if it's undecodable
+func spooledLines(buf []byte) int {
+	_, buf, err := decodeSpooled(buf)
+	if err != nil {
+		return 0
+	}
+	req, err := decodeProtobuf(buf)
+	if err != nil {
+		return 0
+	}
+	return countLines(req)
+}
 
-		if err == nil && statusCode >= 200 && statusCode < 300 {
-			return nil // 发送成功
+// replaySpool sends spooled batches oldest first, stops at the first failure
+// so that the order is kept
+func (l *Loki) replaySpool() error {
+	for {
+		seq, buf, ok, err := l.spool.peek()
+		if err != nil {
+			l.errorHandler(fmt.Errorf("read spooled batch: %w", err))
+			continue
+		}
+		if !ok {
+			return nil
+		}
+		dest, buf, err := decodeSpooled(buf)
+		var (
+			body  []byte
+			lines int
+		)
+		if err == nil {
+			body, lines, err = l.spooledBody(buf)
 		}
-
-		lastErr = err
 		if err != nil {
-			// 记录重试信息
-			fmt.Fprintf(os.Stderr, "%v ERROR: send batch attempt %d failed: %v\n",
-				time.Now(), attempt+1, err)
-		} else {
-			fmt.Fprintf(os.Stderr, "%v ERROR: send batch attempt %d failed with status code: %d\n",
-				time.Now(), attempt+1, statusCode)
+			// Undecodable batch would block the spool forever
+			l.errorHandler(fmt.Errorf("decode spooled batch: %w", err))
+			l.spool.remove(seq)
+			continue
 		}
-
-		// 最后一次重试就不需要等待了
-		if attempt < maxRetries-1 {
-			// 使用指数退避策略，每次重试等待时间翻倍
-			backoffTime := backoffBase * time.Duration(1<<uint(attempt))
-			time.Sleep(backoffTime)
+		if err = l.sendWithRetry(l.ctx, dest, body, lines); err != nil {
+			if !isPermanent(err) {
+				return err
+			}
+			// Rejected batch would block the spool forever
+			l.errorHandler(fmt.Errorf("drop spooled batch: %w", err))
+		}
+		if err = l.spool.remove(seq); err != nil {
+			return err
 		}
 	}
+}
 
-	// 所有重试都失败了
-	return fmt.Errorf("failed to send batch after %d attempts, last error: %v",
-		maxRetries, lastErr)
+// spooledBody encodes the spooled batch by encoder, it's decoded only if necessary
+func (l *Loki) spooledBody(buf []byte) ([]byte, int, error) {
+	_, isProtobuf := l.encoder.(protobufEncoder)
+	if isProtobuf && l.metrics == nil {
+		return buf, 0, nil
+	}
+	req, err := decodeProtobuf(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isProtobuf {
+		return buf, countLines(req), nil
+	}
+	body, err := l.encoder.encode(req)
+	return body, countLines(req), err
 }
 
-func encodeBatch(batch map[model.Fingerprint]*StreamAdapter) ([]byte, error) {
-	req := PushRequest{
+func buildRequest(batch map[model.Fingerprint]*StreamAdapter) *PushRequest {
+	req := &PushRequest{
 		Streams: make([]*StreamAdapter, 0, len(batch)),
 	}
 	for _, stream := range batch {
 		req.Streams = append(req.Streams, stream)
 	}
-	buf, err := proto.Marshal(&req)
-	if err != nil {
-		return nil, err
+	return req
+}
+
+func countLines(req *PushRequest) int {
+	lines := 0
+	for _, stream := range req.Streams {
+		lines += len(stream.Entries)
 	}
-	buf = snappy.Encode(nil, buf)
-	return buf, nil
+	return lines
 }
 
-func (l *Loki) send(ctx context.Context, buf []byte) (int, error) {
-	req, err := http.NewRequest("POST", l.lokiURL, bytes.NewReader(buf))
+// send posts buf once, the status code is -1 on network errors, and retryAfter
+// is parsed from the response
+func (l *Loki) send(ctx context.Context, dest destination, buf []byte) (statusCode int, retryAfter time.Duration, err error) {
+	req, err := http.NewRequest("POST", dest.url, bytes.NewReader(buf))
 	if err != nil {
-		return -1, err
+		return -1, 0, err
 	}
 	req = req.WithContext(ctx)
-	req.Header.Set("Content-Type", contentType)
-	if l.password != "" {
-		req.SetBasicAuth(l.username, l.password)
-	}
-	for key, value := range l.customHeader {
-		req.Header.Set(key, value)
+	req.Header.Set("Content-Type", l.encoder.contentType())
+	if encoding := l.encoder.contentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
 	}
+	setHeaders(req, l.username, l.password, l.customHeader, dest.tenant)
 	resp, err := l.lokiClient.Do(req)
 	if err != nil {
-		return -1, err
+		return -1, 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxErrMsgLen))
-		line := ""
-		if scanner.Scan() {
-			line = scanner.Text()
-		}
-		err = fmt.Errorf("server returned HTTP status %s (%d): %s", resp.Status, resp.StatusCode, line)
+	if err = statusError(resp); err != nil {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return resp.StatusCode, retryAfter, err
+}
+
+// setHeaders sets auth, custom and tenant headers shared by push and query requests
+func setHeaders(req *http.Request, username, password string, customHeader map[string]string, tenant string) {
+	if password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	for key, value := range customHeader {
+		req.Header.Set(key, value)
+	}
+	if tenant != "" {
+		req.Header.Set(tenantHeader, tenant)
+	}
+}
+
+// statusError returns error with the first line of body if status is not 2xx
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxErrMsgLen))
+	line := ""
+	if scanner.Scan() {
+		line = scanner.Text()
 	}
-	return resp.StatusCode, err
+	return fmt.Errorf("server returned HTTP status %s (%d): %s", resp.Status, resp.StatusCode, line)
 }
@@ -120,6 +120,7 @@ func (m *StreamAdapter) GetHash() uint64 {
 type EntryAdapter struct {
 	Timestamp            *timestamp.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Line                 string               `protobuf:"bytes,2,opt,name=line,proto3" json:"line,omitempty"`
+	StructuredMetadata   []*LabelPairAdapter  `protobuf:"bytes,3,rep,name=structuredMetadata,proto3" json:"structuredMetadata,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
 	XXX_unrecognized     []byte               `json:"-"`
 	XXX_sizecache        int32                `json:"-"`
@@ -164,34 +165,94 @@ func (m *EntryAdapter) GetLine() string {
 	return ""
 }
 
+func (m *EntryAdapter) GetStructuredMetadata() []*LabelPairAdapter {
+	if m != nil {
+		return m.StructuredMetadata
+	}
+	return nil
+}
+
+type LabelPairAdapter struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LabelPairAdapter) Reset()         { *m = LabelPairAdapter{} }
+func (m *LabelPairAdapter) String() string { return proto.CompactTextString(m) }
+func (*LabelPairAdapter) ProtoMessage()    {}
+func (*LabelPairAdapter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a3b449635ad5185e, []int{3}
+}
+
+func (m *LabelPairAdapter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LabelPairAdapter.Unmarshal(m, b)
+}
+func (m *LabelPairAdapter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LabelPairAdapter.Marshal(b, m, deterministic)
+}
+func (m *LabelPairAdapter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelPairAdapter.Merge(m, src)
+}
+func (m *LabelPairAdapter) XXX_Size() int {
+	return xxx_messageInfo_LabelPairAdapter.Size(m)
+}
+func (m *LabelPairAdapter) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelPairAdapter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelPairAdapter proto.InternalMessageInfo
+
+func (m *LabelPairAdapter) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *LabelPairAdapter) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*PushRequest)(nil), "loki.PushRequest")
 	proto.RegisterType((*StreamAdapter)(nil), "loki.StreamAdapter")
 	proto.RegisterType((*EntryAdapter)(nil), "loki.EntryAdapter")
+	proto.RegisterType((*LabelPairAdapter)(nil), "loki.LabelPairAdapter")
 }
 
 func init() { proto.RegisterFile("loki.proto", fileDescriptor_a3b449635ad5185e) }
 
 var fileDescriptor_a3b449635ad5185e = []byte{
-	// 309 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x50, 0x4d, 0x4e, 0xf3, 0x30,
-	0x14, 0xfc, 0xdc, 0xe6, 0x6b, 0xe9, 0x2b, 0x3f, 0x92, 0x11, 0x52, 0xa8, 0x90, 0x5c, 0x65, 0xd5,
-	0x4d, 0x5d, 0xa9, 0xac, 0x59, 0x10, 0x81, 0xc4, 0x12, 0xb9, 0x5c, 0x20, 0x01, 0x93, 0x44, 0x24,
-	0x75, 0x88, 0x1d, 0x24, 0x0e, 0x81, 0xc4, 0xb1, 0x38, 0x03, 0x0b, 0xb3, 0xcf, 0x29, 0x50, 0x5e,
-	0x62, 0x0a, 0x9b, 0x64, 0xde, 0x68, 0xde, 0xcc, 0xf3, 0x00, 0xe4, 0xea, 0x29, 0xe3, 0x65, 0xa5,
-	0x8c, 0xa2, 0x5e, 0x8b, 0x67, 0x2c, 0x51, 0x2a, 0xc9, 0xe5, 0x0a, 0xb9, 0xb8, 0x7e, 0x5c, 0x99,
-	0xac, 0x90, 0xda, 0x44, 0x45, 0xd9, 0xc9, 0x66, 0xcb, 0x24, 0x33, 0x69, 0x1d, 0xf3, 0x7b, 0x55,
-	0xac, 0x12, 0x95, 0xa8, 0x9d, 0xb2, 0x9d, 0x70, 0x40, 0xd4, 0xc9, 0x83, 0x0d, 0x4c, 0x6f, 0x6b,
-	0x9d, 0x0a, 0xf9, 0x5c, 0x4b, 0x6d, 0xe8, 0x15, 0x8c, 0xb5, 0xa9, 0x64, 0x54, 0x68, 0x9f, 0xcc,
-	0x87, 0x8b, 0xe9, 0xfa, 0x98, 0xe3, 0x09, 0x1b, 0x24, 0x2f, 0x1f, 0xa2, 0xd2, 0xc8, 0x2a, 0x3c,
-	0xf9, 0xb4, 0x6c, 0xd4, 0x51, 0x8d, 0x65, 0x6e, 0x43, 0x38, 0x10, 0xbc, 0x11, 0x38, 0xf8, 0xb3,
-	0x41, 0x03, 0x18, 0xe5, 0x51, 0x2c, 0xf3, 0xd6, 0x96, 0x2c, 0x26, 0x21, 0x34, 0x96, 0xf5, 0x8c,
-	0xe8, 0xff, 0xf4, 0x02, 0xc6, 0x72, 0x6b, 0xaa, 0x4c, 0x6a, 0x7f, 0x80, 0xd9, 0xb4, 0xcb, 0xbe,
-	0xde, 0x9a, 0xea, 0xd5, 0x45, 0x1f, 0x7d, 0x58, 0xf6, 0xaf, 0x0d, 0xed, 0xa5, 0xc2, 0x01, 0x7a,
-	0x0a, 0x5e, 0x1a, 0xe9, 0xd4, 0x1f, 0xce, 0xc9, 0xc2, 0x0b, 0xff, 0x37, 0x96, 0x91, 0xa5, 0x40,
-	0x2a, 0x78, 0x81, 0xfd, 0xdf, 0x26, 0xf4, 0x06, 0x26, 0x3f, 0xb5, 0xe1, 0x41, 0xd3, 0xf5, 0x8c,
-	0x77, 0xc5, 0x72, 0x57, 0x17, 0xbf, 0x73, 0x8a, 0xf0, 0xb0, 0xcf, 0x1c, 0x18, 0xfd, 0xfe, 0xc5,
-	0x88, 0xd8, 0x2d, 0xd3, 0x33, 0xf0, 0xf2, 0x6c, 0x2b, 0xfd, 0x01, 0xbe, 0x6a, 0xaf, 0xb1, 0x0c,
-	0x67, 0x81, 0xdf, 0x78, 0x84, 0x66, 0xe7, 0xdf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x60, 0x48, 0xbb,
-	0xb6, 0xc7, 0x01, 0x00, 0x00,
+	// 388 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x50, 0xcd, 0xca, 0xd3, 0x40,
+	0x14, 0x35, 0x6d, 0xda, 0xda, 0x5b, 0xff, 0x18, 0x7f, 0x88, 0xa5, 0x30, 0x25, 0xb8, 0xe8, 0xc2,
+	0xa6, 0x50, 0xb7, 0xba, 0x30, 0x28, 0xb8, 0x50, 0x28, 0x53, 0x5f, 0x60, 0xd2, 0x8e, 0x49, 0x30,
+	0xc9, 0xc4, 0x99, 0x1b, 0xa1, 0x5b, 0xf7, 0x82, 0x8f, 0xe5, 0x33, 0xb8, 0x88, 0x08, 0xae, 0xf2,
+	0x14, 0x92, 0x99, 0xc4, 0x7e, 0x7f, 0x9b, 0x99, 0x73, 0x0f, 0xe7, 0xde, 0x73, 0xcf, 0x05, 0xc8,
+	0xe4, 0xe7, 0x34, 0x28, 0x95, 0x44, 0x49, 0xdc, 0x16, 0xcf, 0x69, 0x2c, 0x65, 0x9c, 0x89, 0x8d,
+	0xe1, 0xa2, 0xea, 0xd3, 0x06, 0xd3, 0x5c, 0x68, 0xe4, 0x79, 0x69, 0x65, 0xf3, 0x75, 0x9c, 0x62,
+	0x52, 0x45, 0xc1, 0x41, 0xe6, 0x9b, 0x58, 0xc6, 0xf2, 0xac, 0x6c, 0x2b, 0x53, 0x18, 0x64, 0xe5,
+	0xfe, 0x1e, 0x66, 0xbb, 0x4a, 0x27, 0x4c, 0x7c, 0xa9, 0x84, 0x46, 0xf2, 0x06, 0x26, 0x1a, 0x95,
+	0xe0, 0xb9, 0xf6, 0x9c, 0xe5, 0x70, 0x35, 0xdb, 0x3e, 0x0c, 0xcc, 0x0a, 0x7b, 0x43, 0xbe, 0x3e,
+	0xf2, 0x12, 0x85, 0x0a, 0x1f, 0xff, 0xaa, 0xe9, 0xd8, 0x52, 0x4d, 0x4d, 0xfb, 0x0e, 0xd6, 0x03,
+	0xff, 0xbb, 0x03, 0x77, 0x2f, 0x75, 0x10, 0x1f, 0xc6, 0x19, 0x8f, 0x44, 0xd6, 0x8e, 0x75, 0x56,
+	0xd3, 0x10, 0x9a, 0x9a, 0x76, 0x0c, 0xeb, 0x7e, 0xf2, 0x0a, 0x26, 0xa2, 0x40, 0x95, 0x0a, 0xed,
+	0x0d, 0x8c, 0x37, 0xb1, 0xde, 0x6f, 0x0b, 0x54, 0xa7, 0xde, 0xfa, 0xfe, 0xcf, 0x9a, 0xde, 0x6a,
+	0x4d, 0x3b, 0x29, 0xeb, 0x01, 0x79, 0x0a, 0x6e, 0xc2, 0x75, 0xe2, 0x0d, 0x97, 0xce, 0xca, 0x0d,
+	0x47, 0x4d, 0x4d, 0x9d, 0x35, 0x33, 0x94, 0xff, 0xd7, 0x81, 0x3b, 0x17, 0xa7, 0x90, 0x77, 0x30,
+	0xfd, 0x7f, 0x37, 0xb3, 0xd1, 0x6c, 0x3b, 0x0f, 0xec, 0x65, 0x83, 0xfe, 0x5e, 0xc1, 0xc7, 0x5e,
+	0x11, 0xde, 0xeb, 0x4c, 0x07, 0xa8, 0x7f, 0xfc, 0xa6, 0x0e, 0x3b, 0x37, 0x93, 0x05, 0xb8, 0x59,
+	0x5a, 0x08, 0x6f, 0x60, 0x62, 0xdd, 0x6e, 0x6a, 0x6a, 0x6a, 0x66, 0x5e, 0x92, 0x01, 0xd1, 0xa8,
+	0xaa, 0x03, 0x56, 0x4a, 0x1c, 0x3f, 0x08, 0xe4, 0x47, 0x8e, 0xdc, 0x1b, 0x9a, 0x74, 0x4f, 0x6c,
+	0xba, 0xf7, 0x6d, 0xf8, 0x1d, 0x4f, 0x55, 0x9f, 0xf0, 0x59, 0x6b, 0xf6, 0xed, 0x0f, 0x5d, 0x5c,
+	0xef, 0x7c, 0x2e, 0xf3, 0x14, 0x45, 0x5e, 0xe2, 0x89, 0xdd, 0x30, 0xd7, 0x7f, 0x09, 0x0f, 0xae,
+	0x4e, 0x23, 0x04, 0xdc, 0x82, 0xe7, 0xc2, 0x9e, 0x9d, 0x19, 0x4c, 0x1e, 0xc1, 0xe8, 0x2b, 0xcf,
+	0xaa, 0x6e, 0x69, 0x66, 0x8b, 0x68, 0x6c, 0x82, 0xbf, 0xf8, 0x37, 0x00, 0xe4, 0x0e, 0x03, 0x38,
+	0x74, 0x02, 0x00, 0x00,
 }
@@ -0,0 +1,175 @@
+package loki
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultMetricsNamespace = "loki_client"
+)
+
+var errMetricsConflict = errors.New("metrics of the same name registered by others")
+
+// WithMetrics exports metrics of the client to registry, prometheus default
+// registry is used if nil. Clients sharing a registry should be told apart by
+// constLabels, otherwise counters are shared and gauges follow the newest client
+func WithMetrics(namespace string, constLabels prometheus.Labels, registry prometheus.Registerer) func(*Options) {
+	return func(o *Options) {
+		o.MetricsNamespace = namespace
+		o.MetricsConstLabels = constLabels
+		o.MetricsRegistry = registry
+		o.EnableMetrics = true
+	}
+}
+
+// metrics is nil-safe so the client calls it regardless of enabled or not
+type metrics struct {
+	linesSent     prometheus.Counter
+	bytesSent     prometheus.Counter
+	batchesSent   prometheus.Counter
+	retries       prometheus.Counter
+	failures      prometheus.Counter
+	batchDuration prometheus.Histogram
+}
+
+func newMetrics(l *Loki, namespace string, constLabels prometheus.Labels, registry prometheus.Registerer) (*metrics, error) {
+	if namespace == "" {
+		namespace = DefaultMetricsNamespace
+	}
+	m := &metrics{
+		linesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "sent_lines_total",
+			Help:        "Lines delivered to Loki.",
+			ConstLabels: constLabels,
+		}),
+		bytesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "sent_bytes_total",
+			Help:        "Encoded bytes of batches delivered to Loki.",
+			ConstLabels: constLabels,
+		}),
+		batchesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "sent_batches_total",
+			Help:        "Batches delivered to Loki.",
+			ConstLabels: constLabels,
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "retries_total",
+			Help:        "Retried attempts of sending batches.",
+			ConstLabels: constLabels,
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "failed_batches_total",
+			Help:        "Batches failed after all attempts.",
+			ConstLabels: constLabels,
+		}),
+		batchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "batch_duration_seconds",
+			Help:        "Time of sending a batch including retries.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.005, 2, 14),
+		}),
+	}
+	if registry == nil {
+		registry = prometheus.DefaultRegisterer
+	}
+	for _, c := range []*prometheus.Counter{&m.linesSent, &m.bytesSent, &m.batchesSent, &m.retries, &m.failures} {
+		registered, err := register(registry, *c)
+		if err != nil {
+			return nil, err
+		}
+		counter, ok := registered.(prometheus.Counter)
+		if !ok {
+			return nil, errMetricsConflict
+		}
+		*c = counter
+	}
+	registered, err := register(registry, m.batchDuration)
+	if err != nil {
+		return nil, err
+	}
+	histogram, ok := registered.(prometheus.Histogram)
+	if !ok {
+		return nil, errMetricsConflict
+	}
+	m.batchDuration = histogram
+
+	// Funcs read the client, so they replace ones of previous clients
+	for _, c := range []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "queue_length",
+			Help:        "Lines waiting in queue to be batched.",
+			ConstLabels: constLabels,
+		}, func() float64 { return float64(len(l.payloadCh)) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "dropped_lines_total",
+			Help:        "Lines dropped due to queue overflow, sent after shutdown or evicted from spool.",
+			ConstLabels: constLabels,
+		}, func() float64 { return float64(l.Dropped()) }),
+	} {
+		if err := replace(registry, c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// register returns c once registered, or the one registered before, e.g. by a
+// previous client of the same constLabels
+func register(registry prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := registry.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// replace registers c in place of the one registered before
+func replace(registry prometheus.Registerer, c prometheus.Collector) error {
+	err := registry.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) {
+		return err
+	}
+	registry.Unregister(are.ExistingCollector)
+	return registry.Register(c)
+}
+
+func (m *metrics) sent(buf []byte, lines int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.linesSent.Add(float64(lines))
+	m.bytesSent.Add(float64(len(buf)))
+	m.batchesSent.Inc()
+	m.batchDuration.Observe(elapsed.Seconds())
+}
+
+func (m *metrics) retried() {
+	if m == nil {
+		return
+	}
+	m.retries.Inc()
+}
+
+func (m *metrics) failed(elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.failures.Inc()
+	m.batchDuration.Observe(elapsed.Seconds())
+}
@@ -0,0 +1,134 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsAndErrorHandler(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var (
+		lock   sync.Mutex
+		errors []error
+	)
+	registry := prometheus.NewRegistry()
+	l, err := NewLokiCustomHostname(server.URL, "test", nil,
+		WithMetrics("", nil, registry),
+		WithRetry(testRetryPolicy),
+		WithErrorHandler(func(err error) {
+			lock.Lock()
+			errors = append(errors, err)
+			lock.Unlock()
+		}))
+	require.NoError(t, err)
+	l.Send(time.Now(), map[string]string{"app": "test"}, "first")
+	l.Send(time.Now(), map[string]string{"app": "test"}, "second")
+	l.Close()
+
+	// Failed attempts of a delivered batch are not errors
+	lock.Lock()
+	assert.Empty(t, errors)
+	lock.Unlock()
+	assert.Equal(t, float64(2), testutil.ToFloat64(l.metrics.linesSent))
+	assert.Equal(t, float64(1), testutil.ToFloat64(l.metrics.batchesSent))
+	assert.Equal(t, float64(1), testutil.ToFloat64(l.metrics.retries))
+	assert.Equal(t, float64(0), testutil.ToFloat64(l.metrics.failures))
+	assert.True(t, testutil.ToFloat64(l.metrics.bytesSent) > 0)
+	count, err := testutil.GatherAndCount(registry, "loki_client_queue_length", "loki_client_dropped_lines_total")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestErrorReportedOncePerBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var (
+		lock   sync.Mutex
+		errors []error
+	)
+	l, err := NewLokiCustomHostname(server.URL, "test", nil,
+		WithRetry(testRetryPolicy),
+		WithErrorHandler(func(err error) {
+			lock.Lock()
+			errors = append(errors, err)
+			lock.Unlock()
+		}))
+	require.NoError(t, err)
+	l.Send(time.Now(), map[string]string{"app": "test"}, "line")
+	l.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, errors, 1)
+	assert.Contains(t, errors[0].Error(), "after 3 attempts")
+}
+
+func TestSpoolEvictionDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	l, err := NewLokiCustomHostname(server.URL, "test", nil,
+		WithSpool(t.TempDir(), 1),
+		WithRetry(RetryPolicy{MaxAttempts: 1}),
+		WithMetrics("", nil, registry),
+		WithErrorHandler(func(error) {}))
+	require.NoError(t, err)
+	// Each flush spools a batch, which evicts the previous one beyond 1 byte
+	for _, line := range []string{"a", "b", "c"} {
+		l.Send(time.Now(), map[string]string{"app": "test"}, line)
+		l.Send(time.Now(), map[string]string{"app": "test"}, line)
+		l.Flush(context.Background())
+	}
+	l.Close()
+	assert.Equal(t, uint64(4), l.Dropped())
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var dropped float64
+	for _, f := range families {
+		if f.GetName() == "loki_client_dropped_lines_total" {
+			dropped = f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, float64(4), dropped)
+}
+
+func TestMetricsRegisteredTwice(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	a, err := NewLokiCustomHostname("http://localhost:3100", "test", nil, WithMetrics("", nil, registry))
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := NewLokiCustomHostname("http://localhost:3100", "test", nil, WithMetrics("", nil, registry))
+	require.NoError(t, err)
+	defer b.Close()
+	// Counters are shared by clients of the same constLabels
+	assert.Same(t, a.metrics.linesSent, b.metrics.linesSent)
+	count, err := testutil.GatherAndCount(registry, "loki_client_queue_length", "loki_client_dropped_lines_total")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,111 @@
+package loki
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// streamIdle is how long ordering of a stream is tracked since its last line
+const streamIdle = time.Hour
+
+// OutOfOrderPolicy decides what happens to a line older than the last one of its stream
+type OutOfOrderPolicy int
+
+const (
+	// OutOfOrderRewrite changes time of the line to current
+	OutOfOrderRewrite OutOfOrderPolicy = iota
+	// OutOfOrderSort keeps time of the line, and sorts entries of each stream
+	// within the batch before sending
+	OutOfOrderSort
+	// OutOfOrderKeep keeps time of the line as is, which is HonorOriginTime
+	OutOfOrderKeep
+)
+
+// WithOutOfOrder sets what happens to lines older than the last one of their stream
+func WithOutOfOrder(policy OutOfOrderPolicy) func(*Options) {
+	return func(o *Options) {
+		o.OutOfOrderPolicy = policy
+	}
+}
+
+// AddPrependLabel adds label to streams of lines sent afterwards
+func (l *Loki) AddPrependLabel(key, value string) {
+	l.labelsLock.Lock()
+	defer l.labelsLock.Unlock()
+	l.prependLabels[model.LabelName(key)] = model.LabelValue(value)
+}
+
+// RemovePrependLabel removes label added by AddPrependLabel
+func (l *Loki) RemovePrependLabel(key string) {
+	l.labelsLock.Lock()
+	defer l.labelsLock.Unlock()
+	delete(l.prependLabels, model.LabelName(key))
+}
+
+func (l *Loki) addPrependLabels(labels model.LabelSet) {
+	l.labelsLock.RLock()
+	defer l.labelsLock.RUnlock()
+	for key, value := range l.prependLabels {
+		labels[key] = value
+	}
+}
+
+// streamKey identifies a stream across batches
+type streamKey struct {
+	dest destination
+	fp   model.Fingerprint
+}
+
+// streamTime is the last line time of a stream
+type streamTime struct {
+	last time.Time
+	// seen is when the last line was taken, by which idle streams are pruned
+	seen time.Time
+}
+
+// streamOrder tracks the last line time of each stream, it's used by run only
+type streamOrder map[streamKey]*streamTime
+
+// next returns time of the line at of stream by policy
+func (o streamOrder) next(key streamKey, at time.Time, policy OutOfOrderPolicy) time.Time {
+	now := time.Now()
+	st, ok := o[key]
+	if !ok {
+		st = &streamTime{}
+		o[key] = st
+	}
+	if policy == OutOfOrderRewrite && st.last.After(at) {
+		at = now
+	}
+	if at.After(st.last) {
+		st.last = at
+	}
+	st.seen = now
+	return at
+}
+
+// prune forgets streams idle for streamIdle
+func (o streamOrder) prune() {
+	deadline := time.Now().Add(-streamIdle)
+	for key, st := range o {
+		if st.seen.Before(deadline) {
+			delete(o, key)
+		}
+	}
+}
+
+// sortEntries sorts entries of each stream by time, equal ones keep their order
+func sortEntries(batch map[model.Fingerprint]*StreamAdapter) {
+	for _, stream := range batch {
+		entries := stream.Entries
+		sort.SliceStable(entries, func(i, j int) bool {
+			ti, tj := entries[i].Timestamp, entries[j].Timestamp
+			if ti.GetSeconds() != tj.GetSeconds() {
+				return ti.GetSeconds() < tj.GetSeconds()
+			}
+			return ti.GetNanos() < tj.GetNanos()
+		})
+	}
+}
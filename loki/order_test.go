@@ -0,0 +1,90 @@
+package loki
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamOrder(t *testing.T) {
+	base := time.Now().Add(-time.Minute)
+	a := streamKey{fp: 1}
+	b := streamKey{fp: 2}
+	order := streamOrder{}
+	assert.Equal(t, base.Add(time.Second), order.next(a, base.Add(time.Second), OutOfOrderRewrite))
+	// Ordering is tracked per stream
+	assert.Equal(t, base, order.next(b, base, OutOfOrderRewrite))
+	assert.True(t, order.next(a, base, OutOfOrderRewrite).After(base.Add(time.Second)))
+	assert.Equal(t, base, order.next(a, base, OutOfOrderSort))
+
+	order[b].seen = time.Now().Add(-2 * streamIdle)
+	order.prune()
+	assert.Len(t, order, 1)
+}
+
+func TestOutOfOrderSort(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		received []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := decodeProtobuf(body)
+		require.NoError(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		for _, stream := range req.Streams {
+			for _, e := range stream.Entries {
+				received = append(received, e.Line)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	l, err := NewLokiCustomHostname(server.URL, "test", nil, WithOutOfOrder(OutOfOrderSort))
+	require.NoError(t, err)
+	base := time.Now()
+	l.Send(base.Add(2*time.Second), map[string]string{"app": "test"}, "third")
+	l.Send(base, map[string]string{"app": "test"}, "first")
+	l.Send(base.Add(time.Second), map[string]string{"app": "test"}, "second")
+	require.NoError(t, l.Flush(context.Background()))
+	l.Close()
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []string{"first", "second", "third"}, received)
+}
+
+func TestPrependLabelConcurrently(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	l, err := NewLokiCustomHostname(server.URL, "test", nil)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			l.AddPrependLabel("env", "test")
+			l.RemovePrependLabel("env")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		l.Send(time.Now(), map[string]string{"app": "test"}, "line")
+	}
+	wg.Wait()
+	require.NoError(t, l.Flush(context.Background()))
+	l.Close()
+}
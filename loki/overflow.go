@@ -0,0 +1,151 @@
+package loki
+
+import (
+	"time"
+)
+
+// OverflowPolicy decides what Send does when the payload queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks until the queue has room
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the line being sent
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued line to make room
+	OverflowDropOldest
+	// OverflowBlockTimeout blocks for OverflowTimeout at most, then drops the line being sent
+	OverflowBlockTimeout
+)
+
+// WithOverflow sets the policy of Send on a full queue, timeout is used by OverflowBlockTimeout only
+func WithOverflow(policy OverflowPolicy, timeout time.Duration) func(*Options) {
+	return func(o *Options) {
+		o.OverflowPolicy = policy
+		o.OverflowTimeout = timeout
+	}
+}
+
+// Send queues the line for sending, and handles a full queue by the overflow policy
+func (l *Loki) Send(at time.Time, labels map[string]string, line string) {
+	l.push(payload{
+		at:     at,
+		labels: labels,
+		line:   line,
+	})
+}
+
+// SendWithMetadata is Send with structured metadata attached to the line,
+// which is not indexed as labels are
+func (l *Loki) SendWithMetadata(at time.Time, labels, metadata map[string]string, line string) {
+	l.push(payload{
+		at:       at,
+		labels:   labels,
+		metadata: metadata,
+		line:     line,
+	})
+}
+
+func (l *Loki) push(p payload) {
+	l.enqueue(p, l.overflowPolicy)
+}
+
+// TrySend queues the line without blocking and reports whether it's accepted.
+// With OverflowDropOldest the oldest queued line is dropped to accept it,
+// otherwise the line is dropped once the queue is full. It's false after Shutdown
+func (l *Loki) TrySend(at time.Time, labels map[string]string, line string) bool {
+	return l.tryPush(payload{
+		at:     at,
+		labels: labels,
+		line:   line,
+	})
+}
+
+// TrySendWithMetadata is TrySend with structured metadata attached to the line
+func (l *Loki) TrySendWithMetadata(at time.Time, labels, metadata map[string]string, line string) bool {
+	return l.tryPush(payload{
+		at:       at,
+		labels:   labels,
+		metadata: metadata,
+		line:     line,
+	})
+}
+
+func (l *Loki) tryPush(p payload) bool {
+	if l.overflowPolicy == OverflowDropOldest {
+		return l.enqueue(p, OverflowDropOldest)
+	}
+	return l.enqueue(p, OverflowDropNewest)
+}
+
+// enqueue queues p by policy and reports whether it's accepted. Lines are
+// dropped once Shutdown started, including those blocked on a full queue
+func (l *Loki) enqueue(p payload, policy OverflowPolicy) bool {
+	l.sendLock.RLock()
+	defer l.sendLock.RUnlock()
+	select {
+	case <-l.closing:
+		l.drop()
+		return false
+	default:
+	}
+	switch policy {
+	case OverflowDropNewest:
+		return l.tryEnqueue(p)
+	case OverflowDropOldest:
+		l.enqueueDropOldest(p)
+		return true
+	case OverflowBlockTimeout:
+		timer := time.NewTimer(l.overflowTimeout)
+		defer timer.Stop()
+		select {
+		case l.payloadCh <- p:
+			return true
+		case <-timer.C:
+		case <-l.closing:
+		}
+	default:
+		select {
+		case l.payloadCh <- p:
+			return true
+		case <-l.closing:
+		}
+	}
+	l.drop()
+	return false
+}
+
+// Dropped returns the number of lines dropped due to overflow, sent after
+// Shutdown or evicted from spool
+func (l *Loki) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+func (l *Loki) drop() {
+	l.dropped.Add(1)
+}
+
+func (l *Loki) tryEnqueue(p payload) bool {
+	select {
+	case l.payloadCh <- p:
+		return true
+	default:
+		l.drop()
+		return false
+	}
+}
+
+func (l *Loki) enqueueDropOldest(p payload) {
+	for {
+		select {
+		case l.payloadCh <- p:
+			return
+		default:
+		}
+		select {
+		case <-l.payloadCh:
+			l.drop()
+		default:
+		}
+	}
+}
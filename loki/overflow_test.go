@@ -0,0 +1,81 @@
+package loki
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverflowPolicy(t *testing.T) {
+	newLoki := func(policy OverflowPolicy) *Loki {
+		return &Loki{
+			payloadCh:       make(chan payload, 2),
+			overflowPolicy:  policy,
+			overflowTimeout: 10 * time.Millisecond,
+		}
+	}
+	lines := func(l *Loki) []string {
+		var lines []string
+		for len(l.payloadCh) > 0 {
+			lines = append(lines, (<-l.payloadCh).line)
+		}
+		return lines
+	}
+	now := time.Now()
+
+	l := newLoki(OverflowDropNewest)
+	for _, line := range []string{"a", "b", "c"} {
+		l.Send(now, nil, line)
+	}
+	assert.Equal(t, uint64(1), l.Dropped())
+	assert.Equal(t, []string{"a", "b"}, lines(l))
+
+	l = newLoki(OverflowDropOldest)
+	for _, line := range []string{"a", "b", "c"} {
+		l.Send(now, nil, line)
+	}
+	assert.True(t, l.TrySend(now, nil, "d"))
+	assert.Equal(t, uint64(2), l.Dropped())
+	assert.Equal(t, []string{"c", "d"}, lines(l))
+
+	l = newLoki(OverflowBlockTimeout)
+	for _, line := range []string{"a", "b", "c"} {
+		l.Send(now, nil, line)
+	}
+	assert.Equal(t, uint64(1), l.Dropped())
+	assert.Equal(t, []string{"a", "b"}, lines(l))
+
+	l = newLoki(OverflowBlock)
+	assert.True(t, l.TrySend(now, nil, "a"))
+	assert.True(t, l.TrySend(now, nil, "b"))
+	assert.False(t, l.TrySend(now, nil, "c"))
+	assert.Equal(t, uint64(1), l.Dropped())
+}
+
+func TestSendAfterShutdown(t *testing.T) {
+	r, err := NewReceiver()
+	require.NoError(t, err)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	now := time.Now()
+
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowBlockTimeout} {
+		l, err := NewLokiCustomHostname(server.URL, "test", nil, WithOverflow(policy, 10*time.Millisecond))
+		require.NoError(t, err)
+		l.Close()
+		assert.NotPanics(t, func() {
+			l.Send(now, nil, "a")
+			l.SendWithMetadata(now, nil, map[string]string{"trace_id": "abc"}, "b")
+			l.SendToTenant("foo", now, nil, "c")
+			assert.False(t, l.TrySend(now, nil, "d"))
+			assert.False(t, l.TrySendWithMetadata(now, nil, map[string]string{"trace_id": "abc"}, "e"))
+			assert.False(t, l.TrySendToTenant("foo", now, nil, "f"))
+		}, "policy %d", policy)
+		assert.Equal(t, uint64(6), l.Dropped(), "policy %d", policy)
+		// Shutdown again is no-op
+		l.Close()
+	}
+}
@@ -0,0 +1,488 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	queryPath       = "/loki/api/v1/query"
+	queryRangePath  = "/loki/api/v1/query_range"
+	labelsPath      = "/loki/api/v1/labels"
+	labelValuesPath = "/loki/api/v1/label/%s/values"
+	tailPath        = "/loki/api/v1/tail"
+)
+
+// ResultType is the type of query result
+type ResultType string
+
+const (
+	ResultStreams ResultType = "streams"
+	ResultVector  ResultType = "vector"
+	ResultMatrix  ResultType = "matrix"
+	ResultScalar  ResultType = "scalar"
+)
+
+// Direction is the order of lines in query result
+type Direction string
+
+const (
+	DirectionBackward Direction = "backward"
+	DirectionForward  Direction = "forward"
+)
+
+// QueryClient reads logs back from Loki by HTTP API
+type QueryClient struct {
+	baseURL      string
+	username     string
+	password     string
+	customHeader map[string]string
+	tenant       string
+	client       *http.Client
+	dialer       *websocket.Dialer
+}
+
+// NewQueryClient creates client of Loki at URL, which may be the push URL as
+// well. Auth, LokiTimeout and Tenant of options are used, others are ignored
+func NewQueryClient(URL string, customHeader map[string]string, opts ...func(*Options)) (*QueryClient, error) {
+	options := &Options{
+		LokiTimeout: 10,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+	if i := strings.Index(u.Path, postPath); i >= 0 {
+		u.Path = u.Path[:i]
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	return &QueryClient{
+		baseURL:      u.String(),
+		username:     options.Username,
+		password:     options.Password,
+		customHeader: customHeader,
+		tenant:       options.Tenant,
+		client: &http.Client{
+			Timeout: time.Duration(options.LokiTimeout) * time.Second,
+		},
+		dialer: &websocket.Dialer{
+			HandshakeTimeout: time.Duration(options.LokiTimeout) * time.Second,
+		},
+	}, nil
+}
+
+// QueryResult is the result of Query and QueryRange, the field of Type is set
+type QueryResult struct {
+	Type    ResultType
+	Streams []Stream
+	Vector  []Sample
+	Matrix  []Series
+	Scalar  Point
+	// Stats is left as is since its layout varies across Loki versions
+	Stats json.RawMessage
+}
+
+// Stream is lines of a stream
+type Stream struct {
+	Labels  map[string]string
+	Entries []Entry
+}
+
+type Entry struct {
+	Time               time.Time
+	Line               string
+	StructuredMetadata map[string]string
+	// Parsed are labels extracted by parsers of the query, which are told
+	// apart from StructuredMetadata only if Loki returns categorized labels
+	Parsed map[string]string
+}
+
+// Sample is a point of an instant metric query
+type Sample struct {
+	Metric map[string]string
+	Point
+}
+
+// Series is points of a range metric query
+type Series struct {
+	Metric map[string]string
+	Points []Point
+}
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Query runs an instant query at time at, now if zero. limit is ignored if not positive
+func (c *QueryClient) Query(ctx context.Context, query string, at time.Time, limit int) (*QueryResult, error) {
+	params := url.Values{"query": {query}}
+	setTime(params, "time", at)
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	return c.query(ctx, queryPath, params)
+}
+
+// QueryRange runs a query over [start, end]. step applies to metric queries,
+// zero values of limit, step and direction leave the defaults of Loki
+func (c *QueryClient) QueryRange(ctx context.Context, query string, start, end time.Time, limit int, step time.Duration, direction Direction) (*QueryResult, error) {
+	params := url.Values{"query": {query}}
+	setTime(params, "start", start)
+	setTime(params, "end", end)
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if step > 0 {
+		params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	}
+	if direction != "" {
+		params.Set("direction", string(direction))
+	}
+	return c.query(ctx, queryRangePath, params)
+}
+
+// Labels returns label names within [start, end], zero times leave the defaults of Loki
+func (c *QueryClient) Labels(ctx context.Context, start, end time.Time) ([]string, error) {
+	params := url.Values{}
+	setTime(params, "start", start)
+	setTime(params, "end", end)
+	var labels []string
+	err := c.get(ctx, labelsPath, params, &labels)
+	return labels, err
+}
+
+// LabelValues returns values of label name within [start, end], zero times leave the defaults of Loki
+func (c *QueryClient) LabelValues(ctx context.Context, name string, start, end time.Time) ([]string, error) {
+	params := url.Values{}
+	setTime(params, "start", start)
+	setTime(params, "end", end)
+	var values []string
+	err := c.get(ctx, fmt.Sprintf(labelValuesPath, url.PathEscape(name)), params, &values)
+	return values, err
+}
+
+// TailResponse is a message of Tail
+type TailResponse struct {
+	Streams        []Stream
+	DroppedEntries []DroppedEntry
+}
+
+// DroppedEntry is a line Loki failed to deliver to the tail
+type DroppedEntry struct {
+	Labels map[string]string
+	Time   time.Time
+}
+
+// Tailer receives lines of Tail
+type Tailer struct {
+	conn     *websocket.Conn
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Tail streams lines matching query from start, now if zero. The tail is
+// closed once ctx is done
+func (c *QueryClient) Tail(ctx context.Context, query string, start time.Time, limit int, delayFor time.Duration) (*Tailer, error) {
+	params := url.Values{"query": {query}}
+	setTime(params, "start", start)
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	if delayFor > 0 {
+		params.Set("delay_for", strconv.Itoa(int(delayFor.Seconds())))
+	}
+	u, err := url.Parse(c.baseURL + tailPath)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = params.Encode()
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	// Headers are set on a request so that basic auth is shared with HTTP API
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	setHeaders(req, c.username, c.password, c.customHeader, c.tenant)
+	conn, resp, err := c.dialer.DialContext(ctx, u.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			if statusErr := statusError(resp); statusErr != nil {
+				return nil, statusErr
+			}
+		}
+		return nil, err
+	}
+	t := &Tailer{conn: conn, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-t.stop:
+		}
+	}()
+	return t, nil
+}
+
+// Next blocks until the next message, an error is returned once the tail is
+// closed by either side
+func (t *Tailer) Next() (*TailResponse, error) {
+	var msg struct {
+		Streams        []jsonStreamResult `json:"streams"`
+		DroppedEntries []struct {
+			Labels    map[string]string `json:"labels"`
+			Timestamp string            `json:"timestamp"`
+		} `json:"dropped_entries"`
+	}
+	if err := t.conn.ReadJSON(&msg); err != nil {
+		// The connection is done for, no need to watch ctx anymore
+		t.release()
+		return nil, err
+	}
+	resp := &TailResponse{}
+	for _, s := range msg.Streams {
+		stream, err := s.stream()
+		if err != nil {
+			return nil, err
+		}
+		resp.Streams = append(resp.Streams, stream)
+	}
+	for _, d := range msg.DroppedEntries {
+		at, err := parseNanos(d.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		resp.DroppedEntries = append(resp.DroppedEntries, DroppedEntry{Labels: d.Labels, Time: at})
+	}
+	return resp, nil
+}
+
+func (t *Tailer) Close() error {
+	t.release()
+	return t.conn.Close()
+}
+
+// release stops watching ctx of Tail
+func (t *Tailer) release() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+}
+
+func setTime(params url.Values, key string, t time.Time) {
+	if !t.IsZero() {
+		params.Set(key, strconv.FormatInt(t.UnixNano(), 10))
+	}
+}
+
+// get requests path and decodes data of the response into v
+func (c *QueryClient) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	setHeaders(req, c.username, c.password, c.customHeader, c.tenant)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = statusError(resp); err != nil {
+		return err
+	}
+	body := struct {
+		Status string      `json:"status"`
+		Data   interface{} `json:"data"`
+	}{Data: v}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if body.Status != "success" {
+		return fmt.Errorf("query status %q", body.Status)
+	}
+	return nil
+}
+
+type jsonQueryData struct {
+	ResultType ResultType      `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+	Stats      json.RawMessage `json:"stats"`
+}
+
+type jsonStreamResult struct {
+	Stream map[string]string `json:"stream"`
+	// Values are tuples of timestamp in nanoseconds, line and optional structured metadata
+	Values [][]json.RawMessage `json:"values"`
+}
+
+type jsonMetricResult struct {
+	Metric map[string]string   `json:"metric"`
+	Value  []json.RawMessage   `json:"value"`
+	Values [][]json.RawMessage `json:"values"`
+}
+
+func (c *QueryClient) query(ctx context.Context, path string, params url.Values) (*QueryResult, error) {
+	var data jsonQueryData
+	if err := c.get(ctx, path, params, &data); err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Type: data.ResultType, Stats: data.Stats}
+	var err error
+	switch data.ResultType {
+	case ResultStreams:
+		var streams []jsonStreamResult
+		if err = json.Unmarshal(data.Result, &streams); err != nil {
+			return nil, err
+		}
+		for _, s := range streams {
+			stream, err := s.stream()
+			if err != nil {
+				return nil, err
+			}
+			result.Streams = append(result.Streams, stream)
+		}
+	case ResultVector:
+		var samples []jsonMetricResult
+		if err = json.Unmarshal(data.Result, &samples); err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			point, err := parsePoint(s.Value)
+			if err != nil {
+				return nil, err
+			}
+			result.Vector = append(result.Vector, Sample{Metric: s.Metric, Point: point})
+		}
+	case ResultMatrix:
+		var series []jsonMetricResult
+		if err = json.Unmarshal(data.Result, &series); err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			points := make([]Point, 0, len(s.Values))
+			for _, value := range s.Values {
+				point, err := parsePoint(value)
+				if err != nil {
+					return nil, err
+				}
+				points = append(points, point)
+			}
+			result.Matrix = append(result.Matrix, Series{Metric: s.Metric, Points: points})
+		}
+	case ResultScalar:
+		var value []json.RawMessage
+		if err = json.Unmarshal(data.Result, &value); err != nil {
+			return nil, err
+		}
+		if result.Scalar, err = parsePoint(value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown result type %q", data.ResultType)
+	}
+	return result, nil
+}
+
+func (s jsonStreamResult) stream() (Stream, error) {
+	stream := Stream{Labels: s.Stream, Entries: make([]Entry, 0, len(s.Values))}
+	for _, value := range s.Values {
+		if len(value) < 2 {
+			return stream, fmt.Errorf("malformed entry %s", value)
+		}
+		var (
+			ts string
+			e  Entry
+		)
+		if err := json.Unmarshal(value[0], &ts); err != nil {
+			return stream, err
+		}
+		at, err := parseNanos(ts)
+		if err != nil {
+			return stream, err
+		}
+		e.Time = at
+		if err = json.Unmarshal(value[1], &e.Line); err != nil {
+			return stream, err
+		}
+		if len(value) > 2 {
+			if err = e.parseLabels(value[2]); err != nil {
+				return stream, err
+			}
+		}
+		stream.Entries = append(stream.Entries, e)
+	}
+	return stream, nil
+}
+
+// parseLabels parses labels of the entry, which are structured metadata, or
+// categorized as {"structuredMetadata":{...},"parsed":{...}} if requested by
+// X-Loki-Response-Encoding-Flags
+func (e *Entry) parseLabels(raw json.RawMessage) error {
+	var metadata map[string]string
+	if err := json.Unmarshal(raw, &metadata); err == nil {
+		e.StructuredMetadata = metadata
+		return nil
+	}
+	var categorized struct {
+		StructuredMetadata map[string]string `json:"structuredMetadata"`
+		Parsed             map[string]string `json:"parsed"`
+	}
+	if err := json.Unmarshal(raw, &categorized); err != nil {
+		return err
+	}
+	e.StructuredMetadata, e.Parsed = categorized.StructuredMetadata, categorized.Parsed
+	return nil
+}
+
+func parseNanos(s string) (time.Time, error) {
+	nanos, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed timestamp %q: %w", s, err)
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// parsePoint parses tuple of unix seconds and value string
+func parsePoint(value []json.RawMessage) (Point, error) {
+	if len(value) != 2 {
+		return Point{}, fmt.Errorf("malformed point %s", value)
+	}
+	var (
+		seconds float64
+		v       string
+	)
+	if err := json.Unmarshal(value[0], &seconds); err != nil {
+		return Point{}, err
+	}
+	if err := json.Unmarshal(value[1], &v); err != nil {
+		return Point{}, err
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return Point{}, err
+	}
+	sec := int64(seconds)
+	return Point{
+		Time:  time.Unix(sec, int64((seconds-float64(sec))*1e9)).Round(time.Millisecond),
+		Value: f,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(queryRangePath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, `{app="test"}`, r.URL.Query().Get("query"))
+		assert.Equal(t, "forward", r.URL.Query().Get("direction"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"app":"test"},"values":[["1700000000000000001","hello",{"trace_id":"abc"}],["1700000000000000002","world"],
+				["1700000000000000003","parsed",{"structuredMetadata":{"trace_id":"def"},"parsed":{"level":"info"}}]]}
+		],"stats":{}}}`))
+	})
+	mux.HandleFunc(queryPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"app":"test"},"value":[1700000000.5,"2"]}
+		]}}`))
+	})
+	mux.HandleFunc(labelsPath, func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "secret", password)
+		assert.Equal(t, "foo", r.Header.Get(tenantHeader))
+		assert.Equal(t, "bar", r.Header.Get("X-Custom"))
+		w.Write([]byte(`{"status":"success","data":["app","level"]}`))
+	})
+	mux.HandleFunc("/loki/api/v1/label/app/values", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":["test"]}`))
+	})
+	mux.HandleFunc("/loki/api/v1/label/missing/values", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "label not found", http.StatusNotFound)
+	})
+	mux.HandleFunc(tailPath, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"streams":[
+			{"stream":{"app":"test"},"values":[["1700000000000000003","tailed"]]}
+		],"dropped_entries":[{"labels":{"app":"test"},"timestamp":"1700000000000000004"}]}`))
+		// Keep open until the client closes
+		conn.ReadMessage()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c, err := NewQueryClient(server.URL+postPath, map[string]string{"X-Custom": "bar"},
+		WithAuth("user", "secret"), WithTenant("foo", ""))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := c.QueryRange(ctx, `{app="test"}`, time.Now().Add(-time.Hour), time.Now(), 100, 0, DirectionForward)
+	require.NoError(t, err)
+	require.Equal(t, ResultStreams, result.Type)
+	require.Len(t, result.Streams, 1)
+	assert.Equal(t, map[string]string{"app": "test"}, result.Streams[0].Labels)
+	assert.Equal(t, []Entry{
+		{Time: time.Unix(0, 1700000000000000001), Line: "hello", StructuredMetadata: map[string]string{"trace_id": "abc"}},
+		{Time: time.Unix(0, 1700000000000000002), Line: "world"},
+		{Time: time.Unix(0, 1700000000000000003), Line: "parsed",
+			StructuredMetadata: map[string]string{"trace_id": "def"}, Parsed: map[string]string{"level": "info"}},
+	}, result.Streams[0].Entries)
+
+	result, err = c.Query(ctx, `count_over_time({app="test"}[1m])`, time.Time{}, 0)
+	require.NoError(t, err)
+	require.Equal(t, ResultVector, result.Type)
+	assert.Equal(t, []Sample{{
+		Metric: map[string]string{"app": "test"},
+		Point:  Point{Time: time.UnixMilli(1700000000500), Value: 2},
+	}}, result.Vector)
+
+	labels, err := c.Labels(ctx, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "level"}, labels)
+	values, err := c.LabelValues(ctx, "app", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test"}, values)
+	_, err = c.LabelValues(ctx, "missing", time.Time{}, time.Time{})
+	assert.Error(t, err)
+
+	tailer, err := c.Tail(ctx, `{app="test"}`, time.Time{}, 0, 0)
+	require.NoError(t, err)
+	resp, err := tailer.Next()
+	require.NoError(t, err)
+	require.Len(t, resp.Streams, 1)
+	assert.Equal(t, "tailed", resp.Streams[0].Entries[0].Line)
+	assert.Equal(t, []DroppedEntry{{Labels: map[string]string{"app": "test"}, Time: time.Unix(0, 1700000000000000004)}}, resp.DroppedEntries)
+	require.NoError(t, tailer.Close())
+	_, err = tailer.Next()
+	assert.Error(t, err)
+}
+
+func TestTailClosedByServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	c, err := NewQueryClient(server.URL, nil)
+	require.NoError(t, err)
+	tailer, err := c.Tail(context.Background(), `{app="test"}`, time.Time{}, 0, 0)
+	require.NoError(t, err)
+	_, err = tailer.Next()
+	assert.Error(t, err)
+	// ctx is never done, the watcher ends with reading
+	select {
+	case <-tailer.stop:
+	default:
+		t.Fatal("tail should be released once reading ended")
+	}
+}
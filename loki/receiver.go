@@ -0,0 +1,279 @@
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ReceivedStream is a stream pushed to Receiver, streams of the same labels
+// are told apart by tenant
+type ReceivedStream struct {
+	Tenant string
+	Stream
+}
+
+// Receiver is a minimal in-process Loki stand-in, which is an http.Handler
+// accepting push requests in protobuf or JSON, as Loki does on any path.
+// Received streams are kept in memory for tests, and relayed if configured
+type Receiver struct {
+	lock    sync.Mutex
+	streams []*ReceivedStream
+	// index of streams by tenant and labels
+	index map[string]*ReceivedStream
+	lines int
+	// changed is closed and replaced once lines received
+	changed chan struct{}
+	relay   *relay
+}
+
+type relay struct {
+	url          string
+	username     string
+	password     string
+	customHeader map[string]string
+	client       *http.Client
+}
+
+// WithRelay forwards push requests to Loki at URL before accepting them, so
+// failures of the relay are returned to the pusher. Auth, LokiTimeout and
+// Tenant of options are used, Tenant is the default of requests without one
+func WithRelay(URL string, customHeader map[string]string, opts ...func(*Options)) func(*Receiver) error {
+	return func(r *Receiver) error {
+		options := &Options{
+			LokiTimeout: 10,
+		}
+		for _, opt := range opts {
+			opt(options)
+		}
+		u, err := pushURL(URL)
+		if err != nil {
+			return err
+		}
+		header := make(map[string]string, len(customHeader)+1)
+		if options.Tenant != "" {
+			header[tenantHeader] = options.Tenant
+		}
+		for key, value := range customHeader {
+			header[key] = value
+		}
+		r.relay = &relay{
+			url:          u,
+			username:     options.Username,
+			password:     options.Password,
+			customHeader: header,
+			client: &http.Client{
+				Timeout: time.Duration(options.LokiTimeout) * time.Second,
+			},
+		}
+		return nil
+	}
+}
+
+func NewReceiver(opts ...func(*Receiver) error) (*Receiver, error) {
+	r := &Receiver{
+		index:   make(map[string]*ReceivedStream),
+		changed: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ServeHTTP implements http.Handler, the response is 204 once accepted
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	streams, err := decodePush(req.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenant := req.Header.Get(tenantHeader)
+	if r.relay != nil {
+		statusCode, err := r.relay.forward(req.Context(), req.Header, body)
+		if err != nil {
+			if statusCode < 0 {
+				statusCode = http.StatusBadGateway
+			}
+			http.Error(w, err.Error(), statusCode)
+			return
+		}
+		if tenant == "" {
+			tenant = r.relay.customHeader[tenantHeader]
+		}
+	}
+	r.add(tenant, streams)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Streams returns streams received so far, in the order they first arrived
+func (r *Receiver) Streams() []ReceivedStream {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	streams := make([]ReceivedStream, 0, len(r.streams))
+	for _, s := range r.streams {
+		stream := *s
+		stream.Entries = append([]Entry(nil), s.Entries...)
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// Lines returns the number of lines received so far
+func (r *Receiver) Lines() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lines
+}
+
+// Wait blocks until at least lines received or ctx is done
+func (r *Receiver) Wait(ctx context.Context, lines int) error {
+	for {
+		r.lock.Lock()
+		received, changed := r.lines, r.changed
+		r.lock.Unlock()
+		if received >= lines {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Reset forgets received streams
+func (r *Receiver) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.streams = nil
+	r.index = make(map[string]*ReceivedStream)
+	r.lines = 0
+}
+
+func (r *Receiver) add(tenant string, streams []Stream) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, s := range streams {
+		labels, err := json.Marshal(s.Labels)
+		if err != nil {
+			continue
+		}
+		key := tenant + "\x00" + string(labels)
+		received, ok := r.index[key]
+		if !ok {
+			received = &ReceivedStream{
+				Tenant: tenant,
+				Stream: Stream{Labels: s.Labels},
+			}
+			r.index[key] = received
+			r.streams = append(r.streams, received)
+		}
+		received.Entries = append(received.Entries, s.Entries...)
+		r.lines += len(s.Entries)
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// decodePush decodes body by Content-Type and Content-Encoding as Loki does
+func decodePush(header http.Header, body []byte) ([]Stream, error) {
+	if header.Get("Content-Encoding") == gzipEncoding {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == jsonContentType {
+		var req struct {
+			Streams []jsonStreamResult `json:"streams"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("decode JSON push request: %w", err)
+		}
+		streams := make([]Stream, 0, len(req.Streams))
+		for _, s := range req.Streams {
+			stream, err := s.stream()
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, stream)
+		}
+		return streams, nil
+	}
+
+	req, err := decodeProtobuf(body)
+	if err != nil {
+		return nil, fmt.Errorf("decode protobuf push request: %w", err)
+	}
+	streams := make([]Stream, 0, len(req.Streams))
+	for _, s := range req.Streams {
+		labels, err := parseLabels(s.Labels)
+		if err != nil {
+			return nil, err
+		}
+		stream := Stream{Labels: labels, Entries: make([]Entry, 0, len(s.Entries))}
+		for _, e := range s.Entries {
+			entry := Entry{
+				Time: time.Unix(e.Timestamp.GetSeconds(), int64(e.Timestamp.GetNanos())),
+				Line: e.Line,
+			}
+			if len(e.StructuredMetadata) > 0 {
+				entry.StructuredMetadata = make(map[string]string, len(e.StructuredMetadata))
+				for _, pair := range e.StructuredMetadata {
+					entry.StructuredMetadata[pair.Name] = pair.Value
+				}
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// forward posts body with its content headers, statusCode is -1 on network errors
+func (r *relay) forward(ctx context.Context, header http.Header, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for _, key := range []string{"Content-Type", "Content-Encoding"} {
+		if value := header.Get(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	setHeaders(req, r.username, r.password, r.customHeader, header.Get(tenantHeader))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return -1, fmt.Errorf("relay: %w", err)
+	}
+	defer resp.Body.Close()
+	if err = statusError(resp); err != nil {
+		return resp.StatusCode, fmt.Errorf("relay: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,86 @@
+package loki
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiver(t *testing.T) {
+	upstream, err := NewReceiver()
+	require.NoError(t, err)
+	upstreamServer := httptest.NewServer(upstream)
+	defer upstreamServer.Close()
+
+	r, err := NewReceiver(WithRelay(upstreamServer.URL, nil, WithTenant("default", "")))
+	require.NoError(t, err)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	at := time.Unix(1700000000, 1)
+	for _, encoding := range []func(*Options){WithEncoding(EncodingProtobuf, false), WithEncoding(EncodingJSON, true)} {
+		l, err := NewLokiCustomHostname(server.URL, "test", nil, encoding, WithOthers(10, true))
+		require.NoError(t, err)
+		l.SendWithMetadata(at, map[string]string{"app": "test"}, map[string]string{"trace_id": "abc"}, "hello")
+		l.SendToTenant("foo", at, map[string]string{"app": "test"}, "world")
+		require.NoError(t, l.Flush(context.Background()))
+		l.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Wait(ctx, 4))
+	streams := r.Streams()
+	require.Len(t, streams, 2)
+	byTenant := map[string]ReceivedStream{}
+	for _, s := range streams {
+		byTenant[s.Tenant] = s
+	}
+	hello := Entry{Time: at, Line: "hello", StructuredMetadata: map[string]string{"trace_id": "abc"}}
+	assert.Equal(t, map[string]string{"app": "test"}, byTenant["default"].Labels)
+	assert.Equal(t, []Entry{hello, hello}, byTenant["default"].Entries)
+	assert.Equal(t, []Entry{{Time: at, Line: "world"}, {Time: at, Line: "world"}}, byTenant["foo"].Entries)
+
+	// Relayed as received
+	assert.Equal(t, 4, upstream.Lines())
+	assert.Len(t, upstream.Streams(), 2)
+
+	r.Reset()
+	assert.Equal(t, 0, r.Lines())
+	assert.Empty(t, r.Streams())
+}
+
+func TestReceiverRejects(t *testing.T) {
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "too many streams", http.StatusTooManyRequests)
+	}))
+	defer upstreamServer.Close()
+	r, err := NewReceiver(WithRelay(upstreamServer.URL, nil))
+	require.NoError(t, err)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	body, err := protobufEncoder{}.encode(&PushRequest{})
+	require.NoError(t, err)
+	resp, err = http.Post(server.URL, contentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	resp, err = http.Post(server.URL, jsonContentType, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 0, r.Lines())
+}
@@ -0,0 +1,143 @@
+package loki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy of sending batches. Network errors, 429 and 5xx responses are
+// retried, other responses are taken as permanent failures
+type RetryPolicy struct {
+	// MaxAttempts including the first one
+	MaxAttempts int
+	// MinBackoff is the backoff before the first retry, doubled on each retry
+	MinBackoff time.Duration
+	// MaxBackoff caps the backoff, Retry-After of response is capped as well.
+	// Zero means no cap
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+}
+
+// WithRetry sets the retry policy of sending batches
+func WithRetry(policy RetryPolicy) func(*Options) {
+	return func(o *Options) {
+		o.RetryPolicy = policy
+	}
+}
+
+// backoff returns the jittered backoff before retry, which is between half
+// and full of the exponential one
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	capped := p.MaxBackoff > 0
+	backoff := p.MinBackoff
+	for i := 0; i < retry && (!capped || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if capped && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// permanentError is not retried, and a spooled batch failed by it is dropped
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func isPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+func retryable(statusCode int) bool {
+	// -1 is network error
+	return statusCode < 0 || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// parseRetryAfter parses Retry-After in seconds or HTTP date, returns 0 if absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sendWithRetry sends body to dest by retry policy until ctx is done. Failed
+// attempts are not reported, the returned error tells how many were made
+func (l *Loki) sendWithRetry(ctx context.Context, dest destination, body []byte, lines int) error {
+	policy := l.retryPolicy
+	start := time.Now()
+
+	var (
+		lastErr  error
+		attempts int
+	)
+	for attempts < policy.MaxAttempts {
+		statusCode, retryAfter, err := l.send(ctx, dest, body)
+		attempts++
+		if err == nil {
+			l.metrics.sent(body, lines, time.Since(start))
+			return nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+		if !retryable(statusCode) {
+			l.metrics.failed(time.Since(start))
+			return &permanentError{err: fmt.Errorf("failed to send batch after %d attempts, last error: %w", attempts, err)}
+		}
+		if attempts == policy.MaxAttempts {
+			break
+		}
+
+		backoff := policy.backoff(attempts - 1)
+		if retryAfter > 0 {
+			backoff = retryAfter
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			break
+		}
+		l.metrics.retried()
+	}
+	l.metrics.failed(time.Since(start))
+	return fmt.Errorf("failed to send batch after %d attempts, last error: %w", attempts, lastErr)
+}
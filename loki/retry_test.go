@@ -0,0 +1,117 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  10 * time.Millisecond,
+	MaxBackoff:  100 * time.Millisecond,
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 4 * time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		backoff := p.backoff(retry)
+		assert.True(t, backoff >= max/2 && backoff <= max, "retry %d backoff %v", retry, backoff)
+	}
+
+	// Zero MaxBackoff is uncapped rather than no backoff
+	p = RetryPolicy{MinBackoff: time.Second}
+	for retry, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		backoff := p.backoff(retry)
+		assert.True(t, backoff >= max/2 && backoff <= max, "uncapped retry %d backoff %v", retry, backoff)
+	}
+
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	at := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	assert.True(t, parseRetryAfter(at) > 59*time.Minute)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		header   string
+		attempts int32
+		least    time.Duration
+	}{
+		{name: "bad request is not retried", status: http.StatusBadRequest, attempts: 1},
+		{name: "server error is retried", status: http.StatusBadGateway, attempts: 3},
+		{name: "retry after is honored", status: http.StatusTooManyRequests, header: "1", attempts: 2, least: time.Second},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if c.header != "" {
+					w.Header().Set("Retry-After", c.header)
+				}
+				w.WriteHeader(c.status)
+			}))
+			defer server.Close()
+
+			policy := testRetryPolicy
+			policy.MaxAttempts = int(c.attempts)
+			policy.MaxBackoff = 2 * time.Second
+			l, err := NewLokiCustomHostname(server.URL, "test", nil,
+				WithRetry(policy), WithErrorHandler(func(error) {}))
+			require.NoError(t, err)
+			defer l.Close()
+
+			start := time.Now()
+			l.Send(time.Now(), map[string]string{"app": "test"}, "line")
+			assert.Error(t, l.Flush(context.Background()))
+			assert.True(t, time.Since(start) >= c.least)
+			assert.Equal(t, c.attempts, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestFlushAndShutdown(t *testing.T) {
+	var (
+		requests int32
+		healthy  atomic.Bool
+	)
+	healthy.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	l, err := NewLokiCustomHostname(server.URL, "test", nil,
+		WithRetry(RetryPolicy{MaxAttempts: 100, MinBackoff: time.Second, MaxBackoff: time.Second}),
+		WithErrorHandler(func(error) {}))
+	require.NoError(t, err)
+
+	// Flush sends without waiting for BatchWait
+	l.Send(time.Now(), map[string]string{"app": "test"}, "first")
+	require.NoError(t, l.Flush(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// Shutdown gives up retrying once ctx is done
+	healthy.Store(false)
+	l.Send(time.Now(), map[string]string{"app": "test"}, "second")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, l.Shutdown(ctx))
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, ErrClosed, l.Flush(context.Background()))
+}
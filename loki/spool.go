@@ -0,0 +1,146 @@
+package loki
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	spoolFileSuffix = ".batch"
+	spoolTempSuffix = ".tmp"
+)
+
+type spoolFile struct {
+	seq  uint64
+	size int64
+}
+
+// spool persists encoded batches in dir as files named by sequence, oldest
+// ones are evicted once total size exceeds maxBytes
+type spool struct {
+	dir      string
+	maxBytes int64
+	lock     sync.Mutex
+	nextSeq  uint64
+	files    []spoolFile
+	size     int64
+}
+
+// openSpool creates dir if absent and picks up batches left by previous runs
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxBytes: maxBytes}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, spoolTempSuffix) {
+			// Incomplete write
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, spoolFileSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolFileSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.files = append(s.files, spoolFile{seq: seq, size: info.Size()})
+		s.size += info.Size()
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].seq < s.files[j].seq })
+	if len(s.files) > 0 {
+		s.nextSeq = s.files[len(s.files)-1].seq + 1
+	}
+	return s, nil
+}
+
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolFileSuffix))
+}
+
+// push persists buf as the newest batch and returns evicted batches, an
+// unreadable one is evicted as nil
+func (s *spool) push(buf []byte) ([][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	seq := s.nextSeq
+	path := s.path(seq)
+	// Write then rename, so a crash never leaves a partial batch behind
+	if err := os.WriteFile(path+spoolTempSuffix, buf, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+spoolTempSuffix, path); err != nil {
+		return nil, err
+	}
+	s.nextSeq++
+	s.files = append(s.files, spoolFile{seq: seq, size: int64(len(buf))})
+	s.size += int64(len(buf))
+
+	var evicted [][]byte
+	// The newest batch is always kept
+	for s.size > s.maxBytes && len(s.files) > 1 {
+		seq := s.files[0].seq
+		oldest, _ := os.ReadFile(s.path(seq))
+		s.removeLocked(seq)
+		evicted = append(evicted, oldest)
+	}
+	return evicted, nil
+}
+
+// peek returns the oldest batch, ok is false if the spool is empty. An
+// unreadable batch is dropped with error returned
+func (s *spool) peek() (seq uint64, buf []byte, ok bool, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.files) == 0 {
+		return 0, nil, false, nil
+	}
+	seq = s.files[0].seq
+	buf, err = os.ReadFile(s.path(seq))
+	if err != nil {
+		s.removeLocked(seq)
+		return 0, nil, false, err
+	}
+	return seq, buf, true, nil
+}
+
+// remove drops the batch of seq if it's still in spool
+func (s *spool) remove(seq uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.removeLocked(seq)
+}
+
+func (s *spool) removeLocked(seq uint64) error {
+	for i, f := range s.files {
+		if f.seq != seq {
+			continue
+		}
+		s.files = append(s.files[:i], s.files[i+1:]...)
+		s.size -= f.size
+		return os.Remove(s.path(seq))
+	}
+	return nil
+}
+
+// len returns the number of batches in spool
+func (s *spool) len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.files)
+}
@@ -0,0 +1,88 @@
+package loki
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpool(dir, 10)
+	require.NoError(t, err)
+	for _, buf := range []string{"aaaa", "bbbb", "cccc"} {
+		_, err = s.push([]byte(buf))
+		require.NoError(t, err)
+	}
+	// Oldest is evicted beyond 10 bytes
+	assert.Equal(t, 2, s.len())
+
+	// Batches are picked up in order after reopened
+	s, err = openSpool(dir, 10)
+	require.NoError(t, err)
+	seq, buf, ok, err := s.peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "bbbb", string(buf))
+	require.NoError(t, s.remove(seq))
+	_, buf, _, _ = s.peek()
+	assert.Equal(t, "cccc", string(buf))
+	_, err = s.push([]byte("dddd"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.len())
+}
+
+func TestLokiSpoolReplay(t *testing.T) {
+	var (
+		lock      sync.Mutex
+		available bool
+		received  []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		buf, err := snappy.Decode(nil, body)
+		require.NoError(t, err)
+		var req PushRequest
+		require.NoError(t, proto.Unmarshal(buf, &req))
+		for _, stream := range req.Streams {
+			for _, e := range stream.Entries {
+				received = append(received, e.Line)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	// Batch is kept in spool once all attempts failed
+	l, err := NewLokiCustomHostname(server.URL, "test", nil, WithSpool(dir, 1<<20), WithRetry(testRetryPolicy))
+	require.NoError(t, err)
+	l.Send(time.Now(), map[string]string{"app": "test"}, "first")
+	l.Close()
+	assert.Equal(t, 1, l.spool.len())
+
+	lock.Lock()
+	available = true
+	lock.Unlock()
+	l, err = NewLokiCustomHostname(server.URL, "test", nil, WithSpool(dir, 1<<20), WithRetry(testRetryPolicy))
+	require.NoError(t, err)
+	l.Send(time.Now(), map[string]string{"app": "test"}, "second")
+	l.Close()
+	assert.Equal(t, 0, l.spool.len())
+	assert.Equal(t, []string{"first", "second"}, received)
+}
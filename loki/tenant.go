@@ -0,0 +1,156 @@
+package loki
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+const tenantHeader = "X-Scope-OrgID"
+
+var errMalformedSpooledBatch = errors.New("malformed spooled batch")
+
+// Route sends streams matching all Matchers to URL, a matcher of empty value
+// matches absent label
+type Route struct {
+	Matchers map[string]string
+	URL      string
+}
+
+// WithTenant sets tenant of lines without their own tenant. If label is set,
+// its value in labels of lines is taken as tenant and removed from the stream
+func WithTenant(defaultTenant, label string) func(*Options) {
+	return func(o *Options) {
+		o.Tenant = defaultTenant
+		o.TenantLabel = label
+	}
+}
+
+// WithRoutes sends streams to URL of the first matched route rather than the
+// client URL. Routes are matched against labels including prepended ones
+func WithRoutes(routes ...Route) func(*Options) {
+	return func(o *Options) {
+		o.Routes = append(o.Routes, routes...)
+	}
+}
+
+// SendToTenant is Send with X-Scope-OrgID of the line, lines are batched per tenant
+func (l *Loki) SendToTenant(tenant string, at time.Time, labels map[string]string, line string) {
+	l.push(payload{
+		at:     at,
+		labels: labels,
+		line:   line,
+		tenant: tenant,
+	})
+}
+
+// TrySendToTenant is TrySend with X-Scope-OrgID of the line
+func (l *Loki) TrySendToTenant(tenant string, at time.Time, labels map[string]string, line string) bool {
+	return l.tryPush(payload{
+		at:     at,
+		labels: labels,
+		line:   line,
+		tenant: tenant,
+	})
+}
+
+// destination is where a batch is pushed to
+type destination struct {
+	url    string
+	tenant string
+}
+
+// pendingBatch is the batch being built for a destination
+type pendingBatch struct {
+	streams map[model.Fingerprint]*StreamAdapter
+	size    int
+}
+
+// pushURL appends the push path to URL unless it's present
+func pushURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(u.Path, postPath) {
+		u.Path = postPath
+		q := u.Query()
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	return raw, nil
+}
+
+// destinationOf resolves destination of the entry, the tenant label is removed from labels
+func (l *Loki) destinationOf(p payload, labels model.LabelSet) destination {
+	dest := destination{url: l.lokiURL, tenant: l.tenant}
+	if l.tenantLabel != "" {
+		name := model.LabelName(l.tenantLabel)
+		if tenant, ok := labels[name]; ok {
+			dest.tenant = string(tenant)
+			delete(labels, name)
+		}
+	}
+	if p.tenant != "" {
+		dest.tenant = p.tenant
+	}
+	for _, route := range l.routes {
+		if route.match(labels) {
+			dest.url = route.URL
+			break
+		}
+	}
+	return dest
+}
+
+func (r Route) match(labels model.LabelSet) bool {
+	for name, value := range r.Matchers {
+		if string(labels[model.LabelName(name)]) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedDestinations returns destinations of batches in stable order
+func sortedDestinations(batches map[destination]*pendingBatch) []destination {
+	dests := make([]destination, 0, len(batches))
+	for dest := range batches {
+		dests = append(dests, dest)
+	}
+	sort.Slice(dests, func(i, j int) bool {
+		if dests[i].url != dests[j].url {
+			return dests[i].url < dests[j].url
+		}
+		return dests[i].tenant < dests[j].tenant
+	})
+	return dests
+}
+
+// encodeSpooled prefixes the batch by its destination, each as uvarint length and bytes
+func encodeSpooled(dest destination, buf []byte) []byte {
+	out := make([]byte, 0, 2*binary.MaxVarintLen64+len(dest.url)+len(dest.tenant)+len(buf))
+	for _, field := range []string{dest.url, dest.tenant} {
+		out = binary.AppendUvarint(out, uint64(len(field)))
+		out = append(out, field...)
+	}
+	return append(out, buf...)
+}
+
+func decodeSpooled(buf []byte) (destination, []byte, error) {
+	var fields [2]string
+	for i := range fields {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || uint64(len(buf)-size) < n {
+			return destination{}, nil, errMalformedSpooledBatch
+		}
+		fields[i] = string(buf[size : size+int(n)])
+		buf = buf[size+int(n):]
+	}
+	return destination{url: fields[0], tenant: fields[1]}, buf, nil
+}
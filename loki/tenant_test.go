@@ -0,0 +1,81 @@
+package loki
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantRecorder struct {
+	lock     sync.Mutex
+	received []string
+}
+
+func (r *tenantRecorder) server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		pr, err := decodeProtobuf(body)
+		require.NoError(t, err)
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for _, stream := range pr.Streams {
+			for _, e := range stream.Entries {
+				r.received = append(r.received, req.Header.Get(tenantHeader)+" "+stream.Labels+" "+e.Line)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func (r *tenantRecorder) lines() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	lines := append([]string(nil), r.received...)
+	sort.Strings(lines)
+	return lines
+}
+
+func TestTenantRouting(t *testing.T) {
+	var main, audit tenantRecorder
+	mainServer, auditServer := main.server(t), audit.server(t)
+	defer mainServer.Close()
+	defer auditServer.Close()
+
+	l, err := NewLokiCustomHostname(mainServer.URL, "test", nil,
+		WithTenant("default", "tenant"),
+		WithRoutes(Route{Matchers: map[string]string{"app": "audit"}, URL: auditServer.URL}))
+	require.NoError(t, err)
+	l.Send(time.Now(), map[string]string{"app": "web"}, "a")
+	l.Send(time.Now(), map[string]string{"app": "web", "tenant": "foo"}, "b")
+	l.SendToTenant("bar", time.Now(), map[string]string{"app": "web", "tenant": "foo"}, "c")
+	l.Send(time.Now(), map[string]string{"app": "audit", "tenant": "foo"}, "d")
+	require.NoError(t, l.Flush(context.Background()))
+	l.Close()
+
+	assert.Equal(t, []string{
+		`bar {app="web"} c`,
+		`default {app="web"} a`,
+		`foo {app="web"} b`,
+	}, main.lines())
+	assert.Equal(t, []string{`foo {app="audit"} d`}, audit.lines())
+}
+
+func TestSpooledDestination(t *testing.T) {
+	dest := destination{url: "http://loki/loki/api/v1/push", tenant: "foo"}
+	decoded, buf, err := decodeSpooled(encodeSpooled(dest, []byte("batch")))
+	require.NoError(t, err)
+	assert.Equal(t, dest, decoded)
+	assert.Equal(t, "batch", string(buf))
+
+	_, _, err = decodeSpooled([]byte{10, 'a'})
+	assert.Equal(t, errMalformedSpooledBatch, err)
+}
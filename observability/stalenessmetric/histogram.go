@@ -0,0 +1,30 @@
+package stalenessmetric
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Histogram interface {
+	Holder
+	WithLabelValues(lvs ...string) prometheus.Observer
+}
+
+var _ Histogram = new(stalenessHistogram)
+
+type stalenessHistogram struct {
+	*holder
+}
+
+func NewHistogram(raw *prometheus.HistogramVec, expiration time.Duration, registry prometheus.Registerer) *stalenessHistogram {
+	histogram := &stalenessHistogram{
+		holder: newHolder(raw, expiration),
+	}
+	mustRegister(registry, raw)
+	return histogram
+}
+
+func (h *stalenessHistogram) WithLabelValues(lvs ...string) prometheus.Observer {
+	return h.holder.TryLabelValues(lvs...).(prometheus.Observer)
+}
@@ -0,0 +1,128 @@
+package ping
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Mode is the protocol used to probe a target
+type Mode string
+
+const (
+	ModeICMP Mode = "icmp"
+	ModeTCP  Mode = "tcp"
+	ModeUDP  Mode = "udp"
+)
+
+var ErrUnsupportedMode = errors.New("unsupported probe mode")
+
+// Probe dispatches to Ping, PingTCP or PingUDP according to mode, port is ignored by ICMP
+func Probe(mode Mode, host string, port int, times uint, timeout time.Duration, opts ...OptionFunc) ([]RoundTrip, error) {
+	switch mode {
+	case ModeICMP:
+		return Ping(host, times, timeout, opts...)
+	case ModeTCP:
+		return PingTCP(host, port, times, timeout, opts...)
+	case ModeUDP:
+		return PingUDP(host, port, times, timeout, opts...)
+	}
+	return nil, ErrUnsupportedMode
+}
+
+// PingTCP measures the TCP handshake(SYN -> SYN/ACK) against host:port.
+// A refused connection still reports RTT since the target answered with RST
+func PingTCP(host string, port int, times uint, timeout time.Duration, opts ...OptionFunc) ([]RoundTrip, error) {
+	return pingConnect("tcp", host, port, times, timeout, 0, opts)
+}
+
+// PingUDP sends a datagram to host:port and waits for any reply. Closed ports are
+// reported as connection refused once ICMP port unreachable comes back, silence
+// results in a timeout error
+func PingUDP(host string, port int, times uint, timeout time.Duration, opts ...OptionFunc) ([]RoundTrip, error) {
+	return pingConnect("udp", host, port, times, timeout, 64, opts)
+}
+
+func pingConnect(network, host string, port int, times uint, timeout time.Duration, size int, opts []OptionFunc) ([]RoundTrip, error) {
+	ipaddrs, err := resolve(host, opts)
+	if err != nil {
+		return nil, err
+	}
+	var RTs []RoundTrip
+	for _, ipaddr := range ipaddrs {
+		RTs = append(RTs, newConnPiper(network, ipaddr, port, times, timeout, size).Run()...)
+	}
+	return RTs, nil
+}
+
+type connPiper struct {
+	network          string
+	ipAddr           net.IPAddr
+	port             int
+	checkTimes       uint
+	roundTripTimeout time.Duration
+	size             int
+}
+
+func newConnPiper(network string, ipAddr net.IPAddr, port int,
+	checkTimes uint, roundTripTimeout time.Duration, size int) *connPiper {
+	return &connPiper{
+		network:          network,
+		ipAddr:           ipAddr,
+		port:             port,
+		checkTimes:       checkTimes,
+		roundTripTimeout: roundTripTimeout,
+		size:             size,
+	}
+}
+
+func (p *connPiper) Run() []RoundTrip {
+	RTs := make([]RoundTrip, 0, p.checkTimes)
+	for sequence := 0; sequence < int(p.checkTimes); sequence++ {
+		RTs = append(RTs, p.probe(sequence))
+	}
+	return RTs
+}
+
+func (p *connPiper) probe(sequence int) RoundTrip {
+	RT := RoundTrip{
+		Sequence:  sequence,
+		Target:    p.ipAddr.IP,
+		EmittedAt: time.Now(),
+	}
+	address := net.JoinHostPort(p.ipAddr.String(), strconv.Itoa(p.port))
+	conn, err := net.DialTimeout(p.network, address, p.roundTripTimeout)
+	if err != nil || p.network == "tcp" {
+		p.finish(&RT, 0, err)
+		if conn != nil {
+			conn.Close()
+		}
+		return RT
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(RT.EmittedAt.Add(p.roundTripTimeout))
+	payload := intToBytes(int64(sequence))
+	if p.size > len(payload) {
+		payload = append(payload, make([]byte, p.size-len(payload))...)
+	}
+	if _, err = conn.Write(payload); err != nil {
+		RT.Error = err
+		return RT
+	}
+	received := make([]byte, maxPiperPacketSize)
+	size, err := conn.Read(received)
+	p.finish(&RT, size, err)
+	return RT
+}
+
+func (p *connPiper) finish(RT *RoundTrip, size int, err error) {
+	RT.Error = err
+	// Refused means the target is alive and answered, so the RTT is still meaningful
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		RT.PayloadSize = size
+		RT.RTT = time.Since(RT.EmittedAt)
+	}
+}
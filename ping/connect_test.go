@@ -0,0 +1,133 @@
+package ping_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/ping"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPingTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	RTs, err := ping.PingTCP("127.0.0.1", port, 3, time.Second)
+	require.NoError(t, err)
+	require.Len(t, RTs, 3)
+	for i, RT := range RTs {
+		assert.Equal(t, i, RT.Sequence)
+		assert.NoError(t, RT.Error)
+		assert.True(t, RT.RTT > 0)
+	}
+
+	// Closed port should be refused
+	listener.Close()
+	RTs, err = ping.Probe(ping.ModeTCP, "127.0.0.1", port, 1, time.Second)
+	require.NoError(t, err)
+	require.Len(t, RTs, 1)
+	assert.True(t, errors.Is(RTs[0].Error, syscall.ECONNREFUSED))
+	assert.True(t, RTs[0].RTT > 0)
+}
+
+func TestPingUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	RTs, err := ping.PingUDP("127.0.0.1", port, 3, time.Second)
+	require.NoError(t, err)
+	require.Len(t, RTs, 3)
+	for _, RT := range RTs {
+		assert.NoError(t, RT.Error)
+		assert.Equal(t, 64, RT.PayloadSize)
+	}
+}
+
+func TestProbeUnsupportedMode(t *testing.T) {
+	_, err := ping.Probe("sctp", "127.0.0.1", 0, 1, time.Second)
+	assert.Equal(t, ping.ErrUnsupportedMode, err)
+}
+
+type staticResolver []net.IPAddr
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r, nil
+}
+
+func TestPingAddressFamily(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	resolver := staticResolver{
+		{IP: net.ParseIP("2001:db8::1")},
+		{IP: net.ParseIP("127.0.0.1")},
+		{IP: net.ParseIP("127.0.0.2")},
+	}
+
+	RTs, err := ping.PingTCP("dual.test", port, 1, time.Second, ping.WithResolver(resolver), ping.WithIPv4())
+	require.NoError(t, err)
+	require.Len(t, RTs, 1)
+	assert.Equal(t, "127.0.0.1", RTs[0].Target.String())
+
+	RTs, err = ping.PingTCP("dual.test", port, 2, time.Second,
+		ping.WithResolver(resolver), ping.WithIPv4(), ping.WithAllAddresses())
+	require.NoError(t, err)
+	require.Len(t, RTs, 4)
+	assert.Equal(t, "127.0.0.1", RTs[1].Target.String())
+	assert.Equal(t, "127.0.0.2", RTs[2].Target.String())
+	assert.Equal(t, 0, RTs[2].Sequence)
+
+	// IPv4 is preferred by default even though resolved after IPv6
+	RTs, err = ping.PingTCP("dual.test", port, 1, time.Second, ping.WithResolver(resolver))
+	require.NoError(t, err)
+	require.Len(t, RTs, 1)
+	assert.Equal(t, "127.0.0.1", RTs[0].Target.String())
+
+	_, err = ping.PingTCP("127.0.0.1", port, 1, time.Second, ping.WithIPv6())
+	assert.Equal(t, ping.ErrNoAddress, err)
+}
+
+type blockingResolver struct{}
+
+func (blockingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestResolveTimeout(t *testing.T) {
+	start := time.Now()
+	// Lookup is limited by its own timeout rather than the probe one
+	_, err := ping.PingTCP("slow.test", 80, 1, 10*time.Second,
+		ping.WithResolver(blockingResolver{}), ping.WithResolveTimeout(100*time.Millisecond))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
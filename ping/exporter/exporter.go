@@ -0,0 +1,205 @@
+// Package exporter probes targets continuously and exposes the results as prometheus metrics
+package exporter
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oif/gokit/observability/stalenessmetric"
+	"github.com/oif/gokit/ping"
+	"github.com/oif/gokit/wait"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultNamespace = "ping"
+	DefaultInterval  = 15 * time.Second
+	DefaultTimeout   = time.Second
+	DefaultCount     = 3
+)
+
+var labelNames = []string{"target", "mode"}
+
+// Target is a probe destination, Name is used as metric label and defaults to Host
+type Target struct {
+	Name string
+	Host string
+	Mode ping.Mode
+	// Port is required by TCP and UDP mode
+	Port int
+	// Options of host resolution, results of all addresses are aggregated
+	Options []ping.OptionFunc
+}
+
+func (t Target) label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Host
+}
+
+func (t Target) mode() ping.Mode {
+	if t.Mode == "" {
+		return ping.ModeICMP
+	}
+	return t.Mode
+}
+
+type Config struct {
+	// Interval between two probe rounds
+	Interval time.Duration
+	// Timeout of each round trip
+	Timeout time.Duration
+	// Count of round trips in each probe round
+	Count uint
+	// Namespace of exported metrics
+	Namespace string
+	// Registry to register metrics, prometheus default registry is used if nil
+	Registry prometheus.Registerer
+}
+
+type Exporter struct {
+	config  Config
+	lock    sync.RWMutex
+	targets []Target
+	prober  func(t Target, times uint, timeout time.Duration) ([]ping.RoundTrip, error)
+	// lastSuccessAt of each target by its label values, refreshed to
+	// lastSuccess every round so that it isn't expired during an outage
+	successLock   sync.Mutex
+	lastSuccessAt map[string]time.Time
+
+	rtt         stalenessmetric.Histogram
+	loss        stalenessmetric.Gauge
+	lastSuccess stalenessmetric.Gauge
+}
+
+func New(c Config) *Exporter {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Count == 0 {
+		c.Count = DefaultCount
+	}
+	if c.Namespace == "" {
+		c.Namespace = DefaultNamespace
+	}
+	// Metrics of a target are kept for a few rounds after it was removed
+	expiration := 3 * c.Interval
+	return &Exporter{
+		config:        c,
+		prober:        probe,
+		lastSuccessAt: make(map[string]time.Time),
+		rtt: stalenessmetric.NewHistogram(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.Namespace,
+			Name:      "rtt_seconds",
+			Help:      "Round trip time of successful probes.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, labelNames), expiration, c.Registry),
+		loss: stalenessmetric.NewGauge(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: c.Namespace,
+			Name:      "loss_ratio",
+			Help:      "Ratio of failed round trips in the last probe round.",
+		}, labelNames), expiration, c.Registry),
+		lastSuccess: stalenessmetric.NewGauge(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: c.Namespace,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful round trip.",
+		}, labelNames), expiration, c.Registry),
+	}
+}
+
+func probe(t Target, times uint, timeout time.Duration) ([]ping.RoundTrip, error) {
+	return ping.Probe(t.mode(), t.Host, t.Port, times, timeout, t.Options...)
+}
+
+// SetTargets replaces the probe targets, takes effect from next round
+func (e *Exporter) SetTargets(targets []Target) {
+	e.lock.Lock()
+	e.targets = append([]Target(nil), targets...)
+	e.lock.Unlock()
+
+	// Last success of removed targets expires with their series
+	keep := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keep[successKey(t.labelValues())] = true
+	}
+	e.successLock.Lock()
+	for key := range e.lastSuccessAt {
+		if !keep[key] {
+			delete(e.lastSuccessAt, key)
+		}
+	}
+	e.successLock.Unlock()
+}
+
+// Run probes all targets every Interval until stopCh is closed
+func (e *Exporter) Run(stopCh <-chan struct{}) {
+	wait.Keep(e.probeAll, e.config.Interval, false, stopCh)
+}
+
+func (e *Exporter) probeAll() {
+	e.lock.RLock()
+	targets := e.targets
+	e.lock.RUnlock()
+
+	var g wait.Group
+	for _, target := range targets {
+		target := target
+		g.Run(func() {
+			e.probeTarget(target)
+		})
+	}
+	g.Wait()
+}
+
+func (t Target) labelValues() []string {
+	return []string{t.label(), string(t.mode())}
+}
+
+func successKey(lvs []string) string {
+	return strings.Join(lvs, "\x00")
+}
+
+func (e *Exporter) probeTarget(t Target) {
+	lvs := t.labelValues()
+	RTs, err := e.prober(t, e.config.Count, e.config.Timeout)
+	if err != nil || len(RTs) == 0 {
+		e.loss.WithLabelValues(lvs...).Set(1)
+		e.setLastSuccess(lvs, time.Time{})
+		return
+	}
+	var (
+		failed        int
+		lastSuccessAt time.Time
+	)
+	for _, RT := range RTs {
+		if RT.Error != nil {
+			failed++
+			continue
+		}
+		e.rtt.WithLabelValues(lvs...).Observe(RT.RTT.Seconds())
+		lastSuccessAt = RT.EmittedAt.Add(RT.RTT)
+	}
+	e.loss.WithLabelValues(lvs...).Set(float64(failed) / float64(len(RTs)))
+	e.setLastSuccess(lvs, lastSuccessAt)
+}
+
+// setLastSuccess records at unless it's zero, then sets the last success of
+// target. The series is created on the first success, so it never reads 1970
+func (e *Exporter) setLastSuccess(lvs []string, at time.Time) {
+	key := successKey(lvs)
+	e.successLock.Lock()
+	if !at.IsZero() {
+		e.lastSuccessAt[key] = at
+	}
+	last, ok := e.lastSuccessAt[key]
+	e.successLock.Unlock()
+	if ok {
+		e.lastSuccess.WithLabelValues(lvs...).Set(float64(last.UnixNano()) / float64(time.Second))
+	}
+}
@@ -0,0 +1,67 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/ping"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	e := New(Config{Registry: registry, Count: 4})
+	e.prober = func(target Target, times uint, timeout time.Duration) ([]ping.RoundTrip, error) {
+		if target.Host == "unreachable" {
+			return nil, errors.New("no such host")
+		}
+		now := time.Now()
+		return []ping.RoundTrip{
+			{Sequence: 0, EmittedAt: now, RTT: time.Millisecond},
+			{Sequence: 1, EmittedAt: now, Error: errors.New("timeout")},
+			{Sequence: 2, EmittedAt: now, RTT: 2 * time.Millisecond},
+			{Sequence: 3, EmittedAt: now, RTT: 3 * time.Millisecond},
+		}, nil
+	}
+	e.SetTargets([]Target{
+		{Name: "local", Host: "127.0.0.1"},
+		{Host: "unreachable", Mode: ping.ModeTCP, Port: 80},
+	})
+	e.probeAll()
+
+	assert.Equal(t, 0.25, testutil.ToFloat64(e.loss.WithLabelValues("local", "icmp")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.loss.WithLabelValues("unreachable", "tcp")))
+	// Only the target ever succeeded has last success
+	count, err := testutil.GatherAndCount(registry, "ping_last_success_timestamp_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, testutil.ToFloat64(e.lastSuccess.WithLabelValues("local", "icmp")) > 0)
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "ping_rtt_seconds"))
+}
+
+func TestLastSuccessKeptDuringOutage(t *testing.T) {
+	// Series expire in 3 intervals without being set
+	e := New(Config{Registry: prometheus.NewRegistry(), Interval: 10 * time.Millisecond})
+	down := false
+	e.prober = func(target Target, times uint, timeout time.Duration) ([]ping.RoundTrip, error) {
+		if down {
+			return []ping.RoundTrip{{EmittedAt: time.Now(), Error: errors.New("timeout")}}, nil
+		}
+		return []ping.RoundTrip{{EmittedAt: time.Now(), RTT: time.Millisecond}}, nil
+	}
+	e.SetTargets([]Target{{Name: "local", Host: "127.0.0.1"}})
+	e.probeAll()
+	lastSuccess := testutil.ToFloat64(e.lastSuccess.WithLabelValues("local", "icmp"))
+
+	down = true
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		e.probeAll()
+	}
+	assert.False(t, e.lastSuccess.IsExpired("local", "icmp"))
+	assert.Equal(t, lastSuccess, testutil.ToFloat64(e.lastSuccess.WithLabelValues("local", "icmp")))
+}
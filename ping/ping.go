@@ -9,12 +9,52 @@ import (
 	"golang.org/x/net/ipv6"
 )
 
-func Ping(host string, times uint, timeout time.Duration) ([]RoundTrip, error) {
-	ipaddr, err := net.ResolveIPAddr("ip", host)
+func Ping(host string, times uint, timeout time.Duration, opts ...OptionFunc) ([]RoundTrip, error) {
+	ipaddrs, err := resolve(host, opts)
 	if err != nil {
 		return nil, err
 	}
-	var conn *icmp.PacketConn
+	var (
+		RTs    []RoundTrip
+		failed int
+	)
+	for _, ipaddr := range ipaddrs {
+		addrRTs, err := pingAddr(ipaddr, times, timeout)
+		if err != nil {
+			if len(ipaddrs) == 1 {
+				return nil, err
+			}
+			// Other addresses are still probed, the failed one is reported by its round trips
+			failed++
+			if failed == len(ipaddrs) {
+				return nil, err
+			}
+			addrRTs = failedRoundTrips(ipaddr, times, err)
+		}
+		RTs = append(RTs, addrRTs...)
+	}
+	return RTs, nil
+}
+
+func failedRoundTrips(ipaddr net.IPAddr, times uint, err error) []RoundTrip {
+	now := time.Now()
+	RTs := make([]RoundTrip, 0, times)
+	for i := 0; i < int(times); i++ {
+		RTs = append(RTs, RoundTrip{
+			Sequence:  i,
+			Target:    ipaddr.IP,
+			Error:     err,
+			EmittedAt: now,
+		})
+	}
+	return RTs
+}
+
+func pingAddr(ipaddr net.IPAddr, times uint, timeout time.Duration) ([]RoundTrip, error) {
+	var (
+		conn *icmp.PacketConn
+		err  error
+	)
 	isIPv4 := ipaddr.IP.To4() != nil
 	// Support socket ICMP only currently
 	if isIPv4 {
@@ -31,7 +71,12 @@ func Ping(host string, times uint, timeout time.Duration) ([]RoundTrip, error) {
 		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
 	}
 	defer conn.Close()
-	return newPiper(*ipaddr, conn, isIPv4, times, timeout, 64).Run(), nil
+	return newPiper(ipaddr, &icmpConn{conn, isIPv4}, isIPv4, times, timeout, 64).Run(), nil
+}
+
+// PingConn pings target through the given conn, e.g. a fake transport in testing
+func PingConn(conn PacketConn, target net.IP, times uint, timeout time.Duration) []RoundTrip {
+	return newPiper(net.IPAddr{IP: target}, conn, target.To4() != nil, times, timeout, 64).Run()
 }
 
 func listen(network string) (*icmp.PacketConn, error) {
@@ -42,3 +87,24 @@ func listen(network string) (*icmp.PacketConn, error) {
 	}
 	return conn, nil
 }
+
+// icmpConn adapts icmp.PacketConn to PacketConn
+type icmpConn struct {
+	*icmp.PacketConn
+	isV4 bool
+}
+
+func (c *icmpConn) ReadFrom(b []byte) (int, int, net.Addr, error) {
+	if c.isV4 {
+		size, cm, src, err := c.IPv4PacketConn().ReadFrom(b)
+		if cm != nil {
+			return size, cm.TTL, src, err
+		}
+		return size, 0, src, err
+	}
+	size, cm, src, err := c.IPv6PacketConn().ReadFrom(b)
+	if cm != nil {
+		return size, cm.HopLimit, src, err
+	}
+	return size, 0, src, err
+}
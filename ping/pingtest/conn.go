@@ -0,0 +1,234 @@
+// Package pingtest provides an in-memory ICMP transport to test ping without privileges
+package pingtest
+
+import (
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oif/gokit/ping"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ErrorKind is the ICMP error message responded instead of echo reply
+type ErrorKind int
+
+const (
+	ErrorNone ErrorKind = iota
+	ErrorUnreachable
+	ErrorTimeExceeded
+)
+
+// Reply describes how the fake conn responds to an echo request
+type Reply struct {
+	// Latency before the reply is readable
+	Latency time.Duration
+	// Loss drops the request silently
+	Loss bool
+	// Duplicates is the count of extra copies of the reply
+	Duplicates int
+	// Error responds an ICMP error message instead of echo reply
+	Error ErrorKind
+	// Code of the ICMP error message
+	Code int
+}
+
+// ReplyFunc decides the reply of the echo request with sequence seq
+type ReplyFunc func(seq int) Reply
+
+type packet struct {
+	deliverAt time.Time
+	data      []byte
+}
+
+// Conn is an in-memory ping.PacketConn which replies echo requests itself.
+// Packets are delivered by their delivery time, so different latencies of
+// sequences reorder the replies
+type Conn struct {
+	// TTL reported along with every message
+	TTL int
+	// From is the source address of replies, the destination of request is used if nil
+	From net.Addr
+
+	isV4     bool
+	reply    ReplyFunc
+	lock     sync.Mutex
+	queue    []packet
+	deadline time.Time
+	notify   chan struct{}
+	closed   bool
+	done     chan struct{}
+}
+
+var _ ping.PacketConn = new(Conn)
+
+// NewConn returns a fake conn of IPv4 or IPv6, nil reply means reply immediately
+func NewConn(isV4 bool, reply ReplyFunc) *Conn {
+	if reply == nil {
+		reply = func(int) Reply { return Reply{} }
+	}
+	return &Conn{
+		TTL:    64,
+		isV4:   isV4,
+		reply:  reply,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *Conn) protocol() int {
+	if c.isV4 {
+		return ping.ProtocolICMP
+	}
+	return ping.ProtocolIPv6ICMP
+}
+
+// WriteTo accepts an echo request and schedules the reply
+func (c *Conn) WriteTo(b []byte, dst net.Addr) (int, error) {
+	message, err := icmp.ParseMessage(c.protocol(), b)
+	if err != nil {
+		return 0, err
+	}
+	echo, ok := message.Body.(*icmp.Echo)
+	if !ok {
+		// Not an echo request, nothing to reply
+		return len(b), nil
+	}
+	reply := c.reply(echo.Seq)
+	if reply.Loss {
+		return len(b), nil
+	}
+	data, err := c.makeReply(reply, echo, b)
+	if err != nil {
+		return 0, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if c.From == nil {
+		c.From = dst
+	}
+	deliverAt := time.Now().Add(reply.Latency)
+	for i := 0; i <= reply.Duplicates; i++ {
+		c.queue = append(c.queue, packet{deliverAt: deliverAt, data: data})
+	}
+	sort.SliceStable(c.queue, func(i, j int) bool {
+		return c.queue[i].deliverAt.Before(c.queue[j].deliverAt)
+	})
+	c.wakeup()
+	return len(b), nil
+}
+
+func (c *Conn) makeReply(reply Reply, echo *icmp.Echo, request []byte) ([]byte, error) {
+	message := &icmp.Message{Code: reply.Code}
+	if reply.Error == ErrorNone {
+		message.Type = ipv4.ICMPTypeEchoReply
+		if !c.isV4 {
+			message.Type = ipv6.ICMPTypeEchoReply
+		}
+		message.Code = 0
+		message.Body = echo
+		return message.Marshal(nil)
+	}
+
+	// Quote a minimal IP header and the first 8 bytes of request as real routers do
+	header := make([]byte, ipv4.HeaderLen)
+	header[0] = 4<<4 | ipv4.HeaderLen>>2
+	if !c.isV4 {
+		header = make([]byte, ipv6.HeaderLen)
+		header[0] = 6 << 4
+	}
+	original := append(header, request[:8]...)
+	switch reply.Error {
+	case ErrorUnreachable:
+		message.Type = ipv4.ICMPTypeDestinationUnreachable
+		if !c.isV4 {
+			message.Type = ipv6.ICMPTypeDestinationUnreachable
+		}
+		message.Body = &icmp.DstUnreach{Data: original}
+	case ErrorTimeExceeded:
+		message.Type = ipv4.ICMPTypeTimeExceeded
+		if !c.isV4 {
+			message.Type = ipv6.ICMPTypeTimeExceeded
+		}
+		message.Body = &icmp.TimeExceeded{Data: original}
+	}
+	return message.Marshal(nil)
+}
+
+// ReadFrom blocks until a reply is deliverable, deadline exceeded or conn closed
+func (c *Conn) ReadFrom(b []byte) (int, int, net.Addr, error) {
+	for {
+		c.lock.Lock()
+		if c.closed {
+			c.lock.Unlock()
+			return 0, 0, nil, net.ErrClosed
+		}
+		now := time.Now()
+		wakeAt := c.deadline
+		if len(c.queue) > 0 {
+			p := c.queue[0]
+			if !p.deliverAt.After(now) {
+				c.queue = c.queue[1:]
+				c.lock.Unlock()
+				return copy(b, p.data), c.TTL, c.From, nil
+			}
+			if wakeAt.IsZero() || p.deliverAt.Before(wakeAt) {
+				wakeAt = p.deliverAt
+			}
+		}
+		if !c.deadline.IsZero() && !now.Before(c.deadline) {
+			c.lock.Unlock()
+			return 0, 0, nil, os.ErrDeadlineExceeded
+		}
+		c.lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !wakeAt.IsZero() {
+			timer = time.NewTimer(wakeAt.Sub(now))
+			timeout = timer.C
+		}
+		select {
+		case <-c.notify:
+		case <-timeout:
+		case <-c.done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	c.deadline = t
+	c.wakeup()
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *Conn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+func (c *Conn) wakeup() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
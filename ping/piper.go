@@ -30,9 +30,30 @@ func (rt RoundTrip) String() string {
 		rt.PayloadSize, rt.Target, rt.Sequence, rt.TTL, rt.RTT.Seconds()*1000)
 }
 
+// ICMPError is reported when the target(or a hop on the path) responds an ICMP error
+// message, e.g. destination unreachable or time exceeded, instead of echo reply
+type ICMPError struct {
+	Type icmp.Type
+	Code int
+	From net.Addr
+}
+
+func (e *ICMPError) Error() string {
+	return fmt.Sprintf("%v(code %d) from %v", e.Type, e.Code, e.From)
+}
+
+// PacketConn is the transport piper exchanges ICMP messages through
+type PacketConn interface {
+	WriteTo(b []byte, dst net.Addr) (int, error)
+	// ReadFrom reads an ICMP message(without IP header) and reports the TTL(hop limit of IPv6)
+	ReadFrom(b []byte) (n int, ttl int, src net.Addr, err error)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
 type piper struct {
 	ipAddr           net.IPAddr
-	conn             *icmp.PacketConn
+	conn             PacketConn
 	isV4             bool
 	stopCh           chan struct{}
 	checkTimes       uint
@@ -52,7 +73,7 @@ const (
 	ProtocolIPv6ICMP = 58 // ICMP for IPv6
 )
 
-func newPiper(ipAddr net.IPAddr, conn *icmp.PacketConn, isV4 bool,
+func newPiper(ipAddr net.IPAddr, conn PacketConn, isV4 bool,
 	checkTimes uint, roundTripTimeout time.Duration, size int) *piper {
 	return &piper{
 		ipAddr:           ipAddr,
@@ -123,70 +144,90 @@ func (p *piper) sendMessage() error {
 }
 
 func (p *piper) receivePacket() {
-	var (
-		size int
-		err  error
-	)
-	// read from connection
-	p.conn.SetReadDeadline(time.Now().Add(p.roundTripTimeout))
-	payload := make([]byte, maxPiperPacketSize)
-	var ttl int
-	if p.isV4 {
-		var cm *ipv4.ControlMessage
-		size, cm, _, err = p.conn.IPv4PacketConn().ReadFrom(payload)
-		if cm != nil {
-			ttl = cm.TTL
-		}
-	} else {
-		var cm *ipv6.ControlMessage
-		size, cm, _, err = p.conn.IPv6PacketConn().ReadFrom(payload)
-		if cm != nil {
-			ttl = cm.HopLimit
-		}
-	}
-	receivedAt := time.Now()
 	RT, ok := p.RTs[p.sequence]
 	if !ok {
 		return
 	}
-	dropped := false
-	defer func() {
-		if !dropped {
-			p.RTs[p.sequence] = RT
+	// read from connection
+	p.conn.SetReadDeadline(time.Now().Add(p.roundTripTimeout))
+	payload := make([]byte, maxPiperPacketSize)
+	for {
+		size, ttl, src, err := p.conn.ReadFrom(payload)
+		receivedAt := time.Now()
+		if err != nil {
+			RT.Error = err
+			break
+		}
+		// Skip stale, duplicated or unrelated messages until deadline
+		if p.handleMessage(&RT, payload[:size], ttl, src, receivedAt) {
+			break
 		}
-	}()
-	if err != nil {
-		RT.Error = err
-		return
 	}
+	p.RTs[p.sequence] = RT
+}
 
+func (p *piper) handleMessage(RT *RoundTrip, payload []byte, ttl int, src net.Addr, receivedAt time.Time) bool {
 	icmpProto := ProtocolICMP
+	replyType := icmp.Type(ipv4.ICMPTypeEchoReply)
 	if !p.isV4 {
 		icmpProto = ProtocolIPv6ICMP
+		replyType = ipv6.ICMPTypeEchoReply
 	}
 	message, err := icmp.ParseMessage(icmpProto, payload)
 	if err != nil {
-		RT.Error = err
-		return
+		return false
 	}
+	var original []byte
 	switch data := message.Body.(type) {
 	case *icmp.Echo:
-		if data.ID != p.spanID || data.Seq != p.sequence {
-			dropped = true
-			return
+		if message.Type != replyType || data.ID != p.spanID || data.Seq != p.sequence {
+			return false
 		}
 		if len(data.Data) < p.size {
 			RT.Error = fmt.Errorf("invalid response size: %d(expecte %d)", len(data.Data), p.size)
 		}
 		// Ignore currently data validate
 		RT.TTL = ttl
-		RT.PayloadSize = size
+		RT.PayloadSize = len(payload)
 		RT.RTT = receivedAt.Sub(RT.EmittedAt)
+		return true
+	case *icmp.DstUnreach:
+		original = data.Data
+	case *icmp.TimeExceeded:
+		original = data.Data
 	default:
 		// Unexpected data
-		dropped = true
-		return
+		return false
+	}
+	if !p.isOwnRequest(original) {
+		return false
+	}
+	RT.Error = &ICMPError{
+		Type: message.Type,
+		Code: message.Code,
+		From: src,
+	}
+	RT.RTT = receivedAt.Sub(RT.EmittedAt)
+	return true
+}
+
+// isOwnRequest checks the original datagram quoted by ICMP error message
+// which contains IP header and the first 8 bytes of our echo request
+func (p *piper) isOwnRequest(original []byte) bool {
+	headerLen := ipv6.HeaderLen
+	if p.isV4 {
+		if len(original) == 0 {
+			return false
+		}
+		headerLen = int(original[0]&0x0f) << 2
+	}
+	if len(original) < headerLen+8 {
+		return false
 	}
+	echo := original[headerLen:]
+	id := int(binary.BigEndian.Uint16(echo[4:6]))
+	seq := int(binary.BigEndian.Uint16(echo[6:8]))
+	return id == p.spanID&0xffff && seq == p.sequence&0xffff
 }
 
 func intToBytes(i int64) []byte {
@@ -0,0 +1,64 @@
+package ping_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oif/gokit/ping"
+	"github.com/oif/gokit/ping/pingtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestPingConn(t *testing.T) {
+	timeout := 50 * time.Millisecond
+	replies := map[int]pingtest.Reply{
+		0: {Latency: 5 * time.Millisecond},
+		1: {Loss: true},
+		2: {Duplicates: 2},
+		// Reply of 3 arrives during 4 waiting, should be ignored
+		3: {Latency: timeout + 10*time.Millisecond},
+		4: {Latency: 20 * time.Millisecond},
+		5: {Error: pingtest.ErrorUnreachable, Code: 1},
+		6: {Error: pingtest.ErrorTimeExceeded},
+	}
+	for _, isV4 := range []bool{true, false} {
+		conn := pingtest.NewConn(isV4, func(seq int) pingtest.Reply {
+			return replies[seq]
+		})
+		target := net.ParseIP("192.0.2.1")
+		var unreachable, timeExceeded icmp.Type = ipv4.ICMPTypeDestinationUnreachable, ipv4.ICMPTypeTimeExceeded
+		if !isV4 {
+			target = net.ParseIP("2001:db8::1")
+			unreachable, timeExceeded = ipv6.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeTimeExceeded
+		}
+		RTs := ping.PingConn(conn, target, uint(len(replies)), timeout)
+		conn.Close()
+		require.Len(t, RTs, len(replies))
+
+		assert.NoError(t, RTs[0].Error)
+		assert.Equal(t, 64, RTs[0].TTL)
+		assert.True(t, RTs[0].RTT >= 5*time.Millisecond)
+
+		assert.True(t, errors.Is(RTs[1].Error, os.ErrDeadlineExceeded))
+		assert.NoError(t, RTs[2].Error)
+		assert.True(t, errors.Is(RTs[3].Error, os.ErrDeadlineExceeded))
+
+		assert.NoError(t, RTs[4].Error)
+		assert.True(t, RTs[4].RTT >= 20*time.Millisecond)
+
+		var icmpErr *ping.ICMPError
+		require.True(t, errors.As(RTs[5].Error, &icmpErr))
+		assert.Equal(t, unreachable, icmpErr.Type)
+		assert.Equal(t, 1, icmpErr.Code)
+		require.True(t, errors.As(RTs[6].Error, &icmpErr))
+		assert.Equal(t, timeExceeded, icmpErr.Type)
+	}
+}
@@ -0,0 +1,119 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultResolveTimeout bounds the lookup of host, which is independent of probe timeout
+const DefaultResolveTimeout = 5 * time.Second
+
+var ErrNoAddress = errors.New("no address of required family resolved")
+
+// Resolver looks up addresses of host, *net.Resolver satisfies it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Options of host resolution
+type Options struct {
+	// Network is one of "ip", "ip4" and "ip6"
+	Network string
+	// AllAddresses probes every resolved address rather than the first one
+	AllAddresses bool
+	Resolver     Resolver
+	// ResolveTimeout of host lookup, not limited if not positive
+	ResolveTimeout time.Duration
+}
+
+// OptionFunc contains various option setter
+type OptionFunc func(*Options)
+
+// WithIPv4 only probes IPv4 addresses of host
+func WithIPv4() OptionFunc {
+	return func(o *Options) {
+		o.Network = "ip4"
+	}
+}
+
+// WithIPv6 only probes IPv6 addresses of host
+func WithIPv6() OptionFunc {
+	return func(o *Options) {
+		o.Network = "ip6"
+	}
+}
+
+// WithAllAddresses probes every resolved address in order, results of each
+// address are distinguished by RoundTrip.Target and have their own sequences
+func WithAllAddresses() OptionFunc {
+	return func(o *Options) {
+		o.AllAddresses = true
+	}
+}
+
+// WithResolver resolves host by r instead of net.DefaultResolver
+func WithResolver(r Resolver) OptionFunc {
+	return func(o *Options) {
+		o.Resolver = r
+	}
+}
+
+// WithResolveTimeout limits host lookup to d instead of DefaultResolveTimeout
+func WithResolveTimeout(d time.Duration) OptionFunc {
+	return func(o *Options) {
+		o.ResolveTimeout = d
+	}
+}
+
+// resolve looks up addresses of host within ResolveTimeout, IPv4 ones are
+// preferred for network "ip" as net.ResolveIPAddr does
+func resolve(host string, opts []OptionFunc) ([]net.IPAddr, error) {
+	options := &Options{
+		Network:        "ip",
+		Resolver:       net.DefaultResolver,
+		ResolveTimeout: DefaultResolveTimeout,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var addrs []net.IPAddr
+	if ip := net.ParseIP(host); ip != nil {
+		addrs = []net.IPAddr{{IP: ip}}
+	} else {
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if options.ResolveTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, options.ResolveTimeout)
+		}
+		var err error
+		addrs, err = options.Resolver.LookupIPAddr(ctx, host)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.Network == "ip" {
+		sort.SliceStable(addrs, func(i, j int) bool {
+			return addrs[i].IP.To4() != nil && addrs[j].IP.To4() == nil
+		})
+	}
+
+	var matched []net.IPAddr
+	for _, addr := range addrs {
+		isV4 := addr.IP.To4() != nil
+		if (options.Network == "ip4" && !isV4) || (options.Network == "ip6" && isV4) {
+			continue
+		}
+		matched = append(matched, addr)
+		if !options.AllAddresses {
+			break
+		}
+	}
+	if len(matched) == 0 {
+		return nil, ErrNoAddress
+	}
+	return matched, nil
+}
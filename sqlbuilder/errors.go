@@ -5,9 +5,12 @@ import (
 )
 
 var (
-	ErrConstructFirst  = errors.New("construct the sql first then generate it")
-	ErrMissingTable    = errors.New("missing table")
-	ErrMissingFields   = errors.New("missing fields")
-	ErrInvalidObject   = errors.New("invalid object maybe a nil or not a pointer")
-	ErrUnsupportedKind = errors.New("target sql kind unsupported")
+	ErrConstructFirst    = errors.New("construct the sql first then generate it")
+	ErrMissingTable      = errors.New("missing table")
+	ErrMissingFields     = errors.New("missing fields")
+	ErrMissingValues     = errors.New("missing values")
+	ErrUnknownField      = errors.New("unknown field")
+	ErrInvalidObject     = errors.New("invalid object maybe a nil or not a pointer")
+	ErrUnsupportedKind   = errors.New("target sql kind unsupported")
+	ErrMissingConditions = errors.New("missing where conditions, call AllRows to write all rows")
 )
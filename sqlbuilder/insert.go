@@ -0,0 +1,82 @@
+package sqlbuilder
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Insert inserts object, which is a struct or a slice of structs for batch
+// insert. Fields tagged by sb are inserted except ignoreFields
+func Insert(object interface{}, ignoreFields ...string) *SQL {
+	sql := new(SQL)
+	sql.Kind = KindInsert
+	valueOfObject := reflect.ValueOf(object)
+	if !valueOfObject.IsValid() || valueOfObject.Kind() == reflect.Ptr {
+		sql.err = ErrInvalidObject
+		return sql
+	}
+	if valueOfObject.Kind() == reflect.Slice {
+		for i := 0; i < valueOfObject.Len(); i++ {
+			row := reflect.Indirect(valueOfObject.Index(i))
+			if row.Kind() != reflect.Struct {
+				sql.err = ErrInvalidObject
+				return sql
+			}
+			sql.rows = append(sql.rows, row)
+		}
+		if len(sql.rows) == 0 {
+			sql.err = ErrMissingValues
+			return sql
+		}
+	} else {
+		sql.rows = append(sql.rows, valueOfObject)
+	}
+	if sql.rows[0].Kind() != reflect.Struct {
+		sql.err = ErrInvalidObject
+		return sql
+	}
+	sql.PayloadType = sql.rows[0].Type()
+	for _, row := range sql.rows {
+		if row.Type() != sql.PayloadType {
+			sql.err = ErrInvalidObject
+			return sql
+		}
+	}
+	sql.setFields(ignoreFields)
+	return sql
+}
+
+// Into sets the table to insert into
+func (s *SQL) Into(table string) *SQL {
+	return s.From(table)
+}
+
+func (s *SQL) buildInsertString() (*string, error) {
+	if err := s.errorCast(); err != nil {
+		return nil, err
+	}
+	if s.Table == "" {
+		return nil, ErrMissingTable
+	}
+	if len(s.Fields) == 0 {
+		return nil, ErrMissingFields
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(s.Fields)), ", ") + ")"
+	values := make([]string, 0, len(s.rows))
+	s.Args = make([]interface{}, 0, len(s.rows)*len(s.Fields))
+	for _, row := range s.rows {
+		values = append(values, placeholders)
+		for _, field := range s.Fields {
+			s.Args = append(s.Args, row.FieldByIndex(field.Index).Interface())
+		}
+	}
+	insertParts := []string{
+		"INSERT INTO",
+		s.Table,
+		"(" + strings.Join(s.FieldKeys, ", ") + ")",
+		"VALUES",
+		strings.Join(values, ", "),
+	}
+	insertString := strings.Join(insertParts, space)
+	return &insertString, nil
+}
@@ -0,0 +1,74 @@
+package sqlbuilder_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oif/gokit/sqlbuilder"
+)
+
+type testAccount struct {
+	ID      int64  `sb:"id"`
+	User    string `sb:"user"`
+	Host    string `sb:"host"`
+	Comment string
+	secret  string `sb:"secret"`
+}
+
+func TestInsert(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  *sqlbuilder.SQL
+		expect string
+		args   []interface{}
+		err    error
+	}{
+		{
+			name:   "single row",
+			query:  sqlbuilder.Insert(testAccount{ID: 1, User: "root", Host: "%", secret: "skipped"}).Into("account"),
+			expect: "INSERT INTO account (id, user, host) VALUES (?, ?, ?)",
+			args:   []interface{}{int64(1), "root", "%"},
+		},
+		{
+			name: "batch with ignored field",
+			query: sqlbuilder.Insert([]*testAccount{
+				{User: "root", Host: "%"},
+				{User: "guest", Host: "localhost"},
+			}, "id").Into("account"),
+			expect: "INSERT INTO account (user, host) VALUES (?, ?), (?, ?)",
+			args:   []interface{}{"root", "%", "guest", "localhost"},
+		},
+		{
+			name:  "pointer",
+			query: sqlbuilder.Insert(&testAccount{}).Into("account"),
+			err:   sqlbuilder.ErrInvalidObject,
+		},
+		{
+			name:  "empty batch",
+			query: sqlbuilder.Insert([]testAccount{}).Into("account"),
+			err:   sqlbuilder.ErrMissingValues,
+		},
+		{
+			name:  "missing table",
+			query: sqlbuilder.Insert(testAccount{}),
+			err:   sqlbuilder.ErrMissingTable,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			queryString, err := c.query.Build()
+			if err != c.err {
+				t.Fatalf("expect error %v, got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if *queryString != c.expect {
+				t.Fatalf("expect %q, got %q", c.expect, *queryString)
+			}
+			if !reflect.DeepEqual(c.query.Args, c.args) {
+				t.Fatalf("expect args %v, got %v", c.args, c.query.Args)
+			}
+		})
+	}
+}
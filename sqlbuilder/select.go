@@ -14,22 +14,7 @@ func Select(object interface{}, ignoreFields ...string) *SQL {
 		return sql
 	}
 	sql.PayloadType = typeOfObject
-	quickSearch := make(map[string]bool)
-	for _, k := range ignoreFields {
-		quickSearch[k] = true
-	}
-	for i := 0; i < typeOfObject.NumField(); i++ {
-		field := typeOfObject.Field(i)
-		tag := field.Tag.Get("sb")
-		if _, ignore := quickSearch[tag]; ignore {
-			continue
-		}
-		if tag == "" {
-			continue
-		}
-		sql.Fields = append(sql.Fields, field)
-		sql.FieldKeys = append(sql.FieldKeys, tag)
-	}
+	sql.setFields(ignoreFields)
 	return sql
 }
 
@@ -49,8 +34,10 @@ func (s *SQL) buildSelectString() (*string, error) {
 		"FROM",
 		s.Table,
 	}
+	s.Args = nil
 	if s.Conditions != "" {
 		selectParts = append(selectParts, "WHERE", s.Conditions)
+		s.Args = append(s.Args, s.ConditionArgs...)
 	}
 	if s.OrderByString != "" {
 		selectParts = append(selectParts, s.OrderByString)
@@ -24,6 +24,13 @@ type SQL struct {
 	Table         string
 	OrderByString string
 	Conditions    string
+	ConditionArgs []interface{}
+	// Args are arguments of placeholders in order, which are set by Build
+	Args []interface{}
+	// rows are values to write
+	rows     []reflect.Value
+	omitZero bool
+	allRows  bool
 }
 
 type Kind string
@@ -45,6 +52,27 @@ func (s *SQL) errorCast() error {
 	return nil
 }
 
+// setFields collects fields tagged by sb of PayloadType except ignoreFields,
+// unexported fields are skipped since their values can't be taken
+func (s *SQL) setFields(ignoreFields []string) {
+	quickSearch := make(map[string]bool)
+	for _, k := range ignoreFields {
+		quickSearch[k] = true
+	}
+	for i := 0; i < s.PayloadType.NumField(); i++ {
+		field := s.PayloadType.Field(i)
+		tag := field.Tag.Get("sb")
+		if _, ignore := quickSearch[tag]; ignore {
+			continue
+		}
+		if tag == "" || field.PkgPath != "" {
+			continue
+		}
+		s.Fields = append(s.Fields, field)
+		s.FieldKeys = append(s.FieldKeys, tag)
+	}
+}
+
 func (s *SQL) setTable(table string) {
 	s.Table = table
 }
@@ -64,6 +92,12 @@ func (s *SQL) Build() (*string, error) {
 	switch s.Kind {
 	case KindSelect:
 		return s.buildSelectString()
+	case KindInsert:
+		return s.buildInsertString()
+	case KindUpdate:
+		return s.buildUpdateString()
+	case KindDelete:
+		return s.buildDeleteString()
 	}
 	return nil, ErrUnsupportedKind
 }
@@ -15,13 +15,16 @@ import (
 var engine *sql.DB
 
 func TestMain(m *testing.M) {
-	setup()
-	defer teardown()
-	os.Exit(m.Run())
+	if err := setup(); err != nil {
+		// Building tests run without MySQL, querying ones are skipped
+		fmt.Fprintf(os.Stderr, "MySQL is unreachable: %s\n", err)
+	}
+	code := m.Run()
+	teardown()
+	os.Exit(code)
 }
 
-func setup() {
-	var err error
+func setup() error {
 	testConfig := &mysql.Config{
 		User:                 "root",
 		Passwd:               "123456",
@@ -30,12 +33,22 @@ func setup() {
 		DBName:               "mysql",
 		AllowNativePasswords: true,
 	}
-	engine, err = sql.Open("mysql", testConfig.FormatDSN())
+	db, err := sql.Open("mysql", testConfig.FormatDSN())
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return err
 	}
-	if err = engine.Ping(); err != nil {
-		panic(err)
+	engine = db
+	return nil
+}
+
+// requireMySQL skips tests querying MySQL once it's unreachable
+func requireMySQL(t *testing.T) {
+	if engine == nil {
+		t.Skip("MySQL is unreachable")
 	}
 }
 
@@ -116,6 +129,7 @@ func nativeSelectQuery() ([]TestMySQLAccount, error) {
 }
 
 func TestSelectQuery(t *testing.T) {
+	requireMySQL(t)
 	nativeSelectAccounts, err := nativeSelectQuery()
 	if err != nil {
 		t.Fatal(err)
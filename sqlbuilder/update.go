@@ -0,0 +1,139 @@
+package sqlbuilder
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Update sets fields of object tagged by sb, all of them if fields is empty.
+// WHERE is required unless AllRows
+func Update(object interface{}, fields ...string) *SQL {
+	sql := new(SQL)
+	sql.Kind = KindUpdate
+	valueOfObject := reflect.ValueOf(object)
+	if !valueOfObject.IsValid() || valueOfObject.Kind() != reflect.Struct {
+		sql.err = ErrInvalidObject
+		return sql
+	}
+	sql.PayloadType = valueOfObject.Type()
+	sql.rows = []reflect.Value{valueOfObject}
+	sql.setFields(nil)
+	if len(fields) == 0 {
+		return sql
+	}
+	quickSearch := make(map[string]bool)
+	for _, k := range fields {
+		quickSearch[k] = true
+	}
+	var (
+		selected  []reflect.StructField
+		fieldKeys []string
+	)
+	for i, key := range sql.FieldKeys {
+		if quickSearch[key] {
+			selected = append(selected, sql.Fields[i])
+			fieldKeys = append(fieldKeys, key)
+			delete(quickSearch, key)
+		}
+	}
+	if len(quickSearch) > 0 {
+		sql.err = ErrUnknownField
+		return sql
+	}
+	sql.Fields, sql.FieldKeys = selected, fieldKeys
+	return sql
+}
+
+// Delete deletes rows of table, WHERE is required unless AllRows
+func Delete() *SQL {
+	sql := new(SQL)
+	sql.Kind = KindDelete
+	return sql
+}
+
+// OmitZero skips fields of zero value in Update
+func (s *SQL) OmitZero() *SQL {
+	if err := s.errorCast(); err != nil {
+		return s
+	}
+	s.omitZero = true
+	return s
+}
+
+// AllRows allows Update and Delete without WHERE, which writes all rows of table
+func (s *SQL) AllRows() *SQL {
+	if err := s.errorCast(); err != nil {
+		return s
+	}
+	s.allRows = true
+	return s
+}
+
+// checkConditions guards writes against all rows of table by accident
+func (s *SQL) checkConditions() error {
+	if s.Conditions == "" && !s.allRows {
+		return ErrMissingConditions
+	}
+	return nil
+}
+
+func (s *SQL) buildUpdateString() (*string, error) {
+	if err := s.errorCast(); err != nil {
+		return nil, err
+	}
+	if s.Table == "" {
+		return nil, ErrMissingTable
+	}
+	if err := s.checkConditions(); err != nil {
+		return nil, err
+	}
+	row := s.rows[0]
+	var assignments []string
+	s.Args = nil
+	for i, field := range s.Fields {
+		value := row.FieldByIndex(field.Index)
+		if s.omitZero && value.IsZero() {
+			continue
+		}
+		assignments = append(assignments, s.FieldKeys[i]+" = ?")
+		s.Args = append(s.Args, value.Interface())
+	}
+	if len(assignments) == 0 {
+		return nil, ErrMissingFields
+	}
+	updateParts := []string{
+		"UPDATE",
+		s.Table,
+		"SET",
+		strings.Join(assignments, ", "),
+	}
+	if s.Conditions != "" {
+		updateParts = append(updateParts, "WHERE", s.Conditions)
+		s.Args = append(s.Args, s.ConditionArgs...)
+	}
+	updateString := strings.Join(updateParts, space)
+	return &updateString, nil
+}
+
+func (s *SQL) buildDeleteString() (*string, error) {
+	if err := s.errorCast(); err != nil {
+		return nil, err
+	}
+	if s.Table == "" {
+		return nil, ErrMissingTable
+	}
+	if err := s.checkConditions(); err != nil {
+		return nil, err
+	}
+	deleteParts := []string{
+		"DELETE FROM",
+		s.Table,
+	}
+	s.Args = nil
+	if s.Conditions != "" {
+		deleteParts = append(deleteParts, "WHERE", s.Conditions)
+		s.Args = append(s.Args, s.ConditionArgs...)
+	}
+	deleteString := strings.Join(deleteParts, space)
+	return &deleteString, nil
+}
@@ -0,0 +1,97 @@
+package sqlbuilder_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oif/gokit/sqlbuilder"
+)
+
+func TestUpdateAndDelete(t *testing.T) {
+	account := testAccount{ID: 1, User: "root"}
+	cases := []struct {
+		name   string
+		query  *sqlbuilder.SQL
+		expect string
+		args   []interface{}
+		err    error
+	}{
+		{
+			name:   "update all fields",
+			query:  sqlbuilder.Update(account).From("account").Where("id = ?", 1),
+			expect: "UPDATE account SET id = ?, user = ?, host = ? WHERE id = ?",
+			args:   []interface{}{int64(1), "root", "", 1},
+		},
+		{
+			name:   "update given fields",
+			query:  sqlbuilder.Update(account, "host", "user").From("account").Where("id = ?", 1),
+			expect: "UPDATE account SET user = ?, host = ? WHERE id = ?",
+			args:   []interface{}{"root", "", 1},
+		},
+		{
+			name:   "update non-zero fields",
+			query:  sqlbuilder.Update(account).OmitZero().From("account").Where("id = ?", 1),
+			expect: "UPDATE account SET id = ?, user = ? WHERE id = ?",
+			args:   []interface{}{int64(1), "root", 1},
+		},
+		{
+			name:   "update all rows",
+			query:  sqlbuilder.Update(account, "host").From("account").AllRows(),
+			expect: "UPDATE account SET host = ?",
+			args:   []interface{}{""},
+		},
+		{
+			name:  "update without where",
+			query: sqlbuilder.Update(account).From("account"),
+			err:   sqlbuilder.ErrMissingConditions,
+		},
+		{
+			name:  "update unknown field",
+			query: sqlbuilder.Update(account, "comment").From("account").Where("id = 1"),
+			err:   sqlbuilder.ErrUnknownField,
+		},
+		{
+			name:  "update zero fields only",
+			query: sqlbuilder.Update(account, "host").OmitZero().From("account").Where("id = 1"),
+			err:   sqlbuilder.ErrMissingFields,
+		},
+		{
+			name:  "update pointer",
+			query: sqlbuilder.Update(&account).From("account").Where("id = 1"),
+			err:   sqlbuilder.ErrInvalidObject,
+		},
+		{
+			name:   "delete",
+			query:  sqlbuilder.Delete().From("account").Where("user = ? AND host = ?", "root", "%"),
+			expect: "DELETE FROM account WHERE user = ? AND host = ?",
+			args:   []interface{}{"root", "%"},
+		},
+		{
+			name:   "delete all rows",
+			query:  sqlbuilder.Delete().From("account").AllRows(),
+			expect: "DELETE FROM account",
+		},
+		{
+			name:  "delete without where",
+			query: sqlbuilder.Delete().From("account"),
+			err:   sqlbuilder.ErrMissingConditions,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			queryString, err := c.query.Build()
+			if err != c.err {
+				t.Fatalf("expect error %v, got %v", c.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if *queryString != c.expect {
+				t.Fatalf("expect %q, got %q", c.expect, *queryString)
+			}
+			if !reflect.DeepEqual(c.query.Args, c.args) {
+				t.Fatalf("expect args %v, got %v", c.args, c.query.Args)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package sqlbuilder
 
-func (s *SQL) Where(conditions string) *SQL {
+// Where sets conditions, args are arguments of placeholders in conditions
+func (s *SQL) Where(conditions string, args ...interface{}) *SQL {
 	if err := s.errorCast(); err != nil {
 		return s
 	}
 	s.Conditions = conditions
+	s.ConditionArgs = args
 	return s
 }